@@ -11,6 +11,12 @@ import (
 func main() {
 	// Loading environment variables
 	godotenv.Load()
+
+	// Running the migrations subcommand instead of the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
 	host := os.Getenv("HOST")
 	port := os.Getenv("PORT")
 
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/data"
+)
+
+const migrateUsage = `usage: migrate <command>
+
+commands:
+  up          apply all the pending migrations
+  down [N]    revert the last N applied migrations (default 1)
+  status      list the migrations and whether they are applied
+  to N        apply or revert migrations until the database is at version N`
+
+// runMigrate executes the 'migrate' subcommand with the given arguments.
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+
+	db, err := data.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer db.Close()
+
+	migrator, err := data.NewMigrator(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatalf("invalid number of steps '%s'", args[1])
+			}
+		}
+		err = migrator.Down(ctx, steps)
+	case "to":
+		if len(args) < 2 {
+			log.Fatal(migrateUsage)
+		}
+		version, err_parse := strconv.Atoi(args[1])
+		if err_parse != nil {
+			log.Fatalf("invalid version '%s'", args[1])
+		}
+		err = migrator.To(ctx, version)
+	case "status":
+		err = printMigrationStatus(ctx, migrator)
+	default:
+		log.Fatal(migrateUsage)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printMigrationStatus(ctx context.Context, migrator *data.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.Applied() {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	return w.Flush()
+}
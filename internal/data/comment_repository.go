@@ -0,0 +1,113 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/comment"
+)
+
+type CommentRepository struct {
+	Data *Data
+}
+
+func NewCommentRepository(connection *Data) *CommentRepository {
+	return &CommentRepository{
+		Data: connection,
+	}
+}
+
+// GetByPost returns the comments of the post down to the given depth, parents before their replies.
+func (repository *CommentRepository) GetByPost(ctx context.Context, postId uint, maxDepth int) ([]comment.Comment, error) {
+	query := `
+	SELECT id, post_id, COALESCE(user_id, 0), parent_id, depth, body, created_at, COALESCE(updated_at, '0001-01-01T00:00:00Z'), deleted_at
+	FROM comments
+	WHERE post_id = $1 AND depth <= $2
+	ORDER BY depth, created_at, id;
+	`
+	rows, err := repository.Data.DB.QueryContext(ctx, query, postId, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var comments []comment.Comment
+	for rows.Next() {
+		var c comment.Comment
+		err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.ParentID, &c.Depth, &c.Body, &c.CreatedAt, &c.UpdatedAt, &c.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		c.Deleted = c.DeletedAt != nil
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+func (repository *CommentRepository) GetById(ctx context.Context, id uint) (comment.Comment, error) {
+	query := `
+	SELECT id, post_id, COALESCE(user_id, 0), parent_id, depth, body, created_at, COALESCE(updated_at, '0001-01-01T00:00:00Z'), deleted_at
+	FROM comments
+	WHERE id = $1;
+	`
+	row := repository.Data.DB.QueryRowContext(ctx, query, id)
+	var c comment.Comment
+	err := row.Scan(&c.ID, &c.PostID, &c.UserID, &c.ParentID, &c.Depth, &c.Body, &c.CreatedAt, &c.UpdatedAt, &c.DeletedAt)
+	if err != nil {
+		return comment.Comment{}, translateError(err)
+	}
+	c.Deleted = c.DeletedAt != nil
+	return c, nil
+}
+
+func (repository *CommentRepository) Create(ctx context.Context, comment *comment.Comment) error {
+	insert := `
+	INSERT INTO comments (post_id, user_id, parent_id, depth, body, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id;
+	`
+	row := repository.Data.DB.QueryRowContext(ctx, insert,
+		comment.PostID, comment.UserID, comment.ParentID, comment.Depth, comment.Body, comment.CreatedAt,
+	)
+	return translateError(row.Scan(&comment.ID))
+}
+
+func (repository *CommentRepository) Update(ctx context.Context, id uint, comment comment.Comment) error {
+	update := `
+	UPDATE comments SET body=$1, updated_at=$2
+	WHERE id=$3 AND deleted_at IS NULL;
+	`
+	result, err := repository.Data.DB.ExecContext(ctx, update, comment.Body, comment.UpdatedAt, id)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return notFound("comment", id)
+	}
+	return nil
+}
+
+// SoftDelete marks the comment as deleted and erases its body, keeping the row so that its replies stay in the thread.
+func (repository *CommentRepository) SoftDelete(ctx context.Context, id uint) error {
+	delete := `
+	UPDATE comments SET body='', deleted_at=$1
+	WHERE id=$2 AND deleted_at IS NULL;
+	`
+	result, err := repository.Data.DB.ExecContext(ctx, delete, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return notFound("comment", id)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"sync"
@@ -17,12 +18,17 @@ type Data struct {
 }
 
 func initDB() {
-	db, err := getConnection()
+	db, err := Open()
 	if err != nil {
 		log.Panic(err)
 	}
 
-	err = MakeMigration(db)
+	// Applying the pending migrations on startup
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		log.Panic(err)
+	}
+	err = migrator.Up(context.Background())
 	if err != nil {
 		log.Panic(err)
 	}
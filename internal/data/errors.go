@@ -0,0 +1,52 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+	"github.com/lib/pq"
+)
+
+// uniqueViolation is the code Postgres reports when a change violates a unique constraint
+const uniqueViolation = "23505"
+
+// translateError turns the errors of the driver that the services handle into the errors they
+// expect: a missing row wraps errors.ErrNotFound and a unique violation becomes an *errors.ConflictError.
+// Any other error is returned as it is.
+func translateError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %w", errors.ErrNotFound, err)
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return &errors.ConflictError{Constraint: pqErr.Constraint, Err: err}
+	}
+	return err
+}
+
+// notFound is returned by the changes that didn't affect any row.
+func notFound(resource string, id uint) error {
+	return fmt.Errorf("%w: the %s with id '%d' does not exist", errors.ErrNotFound, resource, id)
+}
+
+// rowQuerier is either the database or a transaction, so that a change made in a transaction is
+// looked into from that transaction.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// notChanged is returned by the changes made only when the row is at the version that didn't affect any
+// row, telling whether the row does not exist, or is deleted, or is at another version.
+func notChanged(ctx context.Context, q rowQuerier, table string, resource string, id uint, version int) error {
+	var exists bool
+	query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE id = $1 AND deleted_at IS NULL);`, table)
+	if err := q.QueryRowContext(ctx, query, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return notFound(resource, id)
+	}
+	return fmt.Errorf("%w: the %s with id '%d' is no longer at version %d", errors.ErrVersionMismatch, resource, id, version)
+}
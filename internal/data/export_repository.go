@@ -0,0 +1,316 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/comment"
+	"github.com/cortzero/go-postgres-blog/internal/model/export"
+)
+
+// exportColumns are the columns of an export, without its archive
+const exportColumns = `id, user_id, status, error, COALESCE(octet_length(archive), 0), created_at, completed_at, expires_at`
+
+type ExportRepository struct {
+	Data *Data
+}
+
+func NewExportRepository(connection *Data) *ExportRepository {
+	return &ExportRepository{
+		Data: connection,
+	}
+}
+
+// Count returns the number of posts, comments and revisions of posts of the user, which tells how long
+// exporting them takes. The user is found even when it is in the trash.
+func (repository *ExportRepository) Count(ctx context.Context, userId uint) (int, error) {
+	query := `
+	SELECT (SELECT COUNT(*) FROM posts WHERE user_id = u.id)
+		+ (SELECT COUNT(*) FROM comments WHERE user_id = u.id)
+		+ (SELECT COUNT(*) FROM post_revisions WHERE editor_id = u.id)
+	FROM users u
+	WHERE u.id = $1;
+	`
+	var count int
+	if err := repository.Data.DB.QueryRowContext(ctx, query, userId).Scan(&count); err != nil {
+		return 0, translateError(err)
+	}
+	return count, nil
+}
+
+// Collect gathers everything stored about the user from a single snapshot of the database, including
+// the posts and comments in the trash.
+func (repository *ExportRepository) Collect(ctx context.Context, userId uint) (export.Contents, error) {
+	profile := `
+	SELECT id, first_name, last_name, username, email, picture, role, version, created_at, COALESCE(updated_at, '0001-01-01T00:00:00Z')
+	FROM users
+	WHERE id = $1;
+	`
+	posts := fmt.Sprintf(`
+	SELECT %s, p.deleted_at
+	FROM posts p
+	WHERE p.user_id = $1
+	ORDER BY p.created_at, p.id;
+	`, postColumns)
+	comments := `
+	SELECT id, post_id, COALESCE(user_id, 0), parent_id, depth, body, created_at, COALESCE(updated_at, '0001-01-01T00:00:00Z'), deleted_at
+	FROM comments
+	WHERE user_id = $1
+	ORDER BY created_at, id;
+	`
+	// A session is a family of refresh tokens, from the one issued at login to the last one it was rotated into
+	sessions := `
+	SELECT id, created_at, expires_at, revoked_at
+	FROM (
+		SELECT DISTINCT ON (family_id)
+			MIN(id) OVER family AS id,
+			COALESCE(MIN(created_at) OVER family, '0001-01-01T00:00:00Z') AS created_at,
+			expires_at,
+			revoked_at
+		FROM refresh_tokens
+		WHERE user_id = $1
+		WINDOW family AS (PARTITION BY family_id)
+		ORDER BY family_id, refresh_tokens.id DESC
+	) s
+	ORDER BY created_at, id;
+	`
+	audit := `
+	SELECT post_id, revision, title, created_at
+	FROM post_revisions
+	WHERE editor_id = $1
+	ORDER BY created_at, id;
+	`
+	tx, err := repository.Data.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return export.Contents{}, err
+	}
+
+	defer tx.Rollback()
+
+	var contents export.Contents
+	u := &contents.User
+	err = tx.QueryRowContext(ctx, profile, userId).Scan(&u.ID, &u.FirstName, &u.LastName, &u.Username, &u.Email,
+		&u.Picture, &u.Role, &u.Version, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return export.Contents{}, translateError(err)
+	}
+
+	if contents.Posts, err = collectPosts(ctx, tx, posts, userId); err != nil {
+		return export.Contents{}, err
+	}
+	if contents.Comments, err = collectComments(ctx, tx, comments, userId); err != nil {
+		return export.Contents{}, err
+	}
+	if contents.Sessions, err = collectSessions(ctx, tx, sessions, userId); err != nil {
+		return export.Contents{}, err
+	}
+	if contents.Audit, err = collectAudit(ctx, tx, audit, userId); err != nil {
+		return export.Contents{}, err
+	}
+	return contents, tx.Commit()
+}
+
+// Create stores a pending export for the user, or returns the one that is already pending.
+func (repository *ExportRepository) Create(ctx context.Context, userId uint) (export.Export, error) {
+	insert := fmt.Sprintf(`
+	INSERT INTO user_exports (user_id, status, created_at)
+	VALUES ($1, 'pending', $2)
+	ON CONFLICT (user_id) WHERE status = 'pending' DO UPDATE SET user_id = EXCLUDED.user_id
+	RETURNING %s;
+	`, exportColumns)
+	row := repository.Data.DB.QueryRowContext(ctx, insert, userId, time.Now())
+	var e export.Export
+	if err := scanExport(row, &e); err != nil {
+		return export.Export{}, translateError(err)
+	}
+	return e, nil
+}
+
+// GetById returns the export of the user unless it has expired.
+func (repository *ExportRepository) GetById(ctx context.Context, userId uint, id uint) (export.Export, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM user_exports
+	WHERE id = $1 AND user_id = $2 AND (expires_at IS NULL OR expires_at > $3);
+	`, exportColumns)
+	row := repository.Data.DB.QueryRowContext(ctx, query, id, userId, time.Now())
+	var e export.Export
+	if err := scanExport(row, &e); err != nil {
+		return export.Export{}, translateError(err)
+	}
+	return e, nil
+}
+
+// GetArchive returns the archive of the export of the user once it is ready, unless it has expired.
+func (repository *ExportRepository) GetArchive(ctx context.Context, userId uint, id uint) ([]byte, error) {
+	query := `
+	SELECT archive
+	FROM user_exports
+	WHERE id = $1 AND user_id = $2 AND status = 'ready' AND expires_at > $3;
+	`
+	var archive []byte
+	if err := repository.Data.DB.QueryRowContext(ctx, query, id, userId, time.Now()).Scan(&archive); err != nil {
+		return nil, translateError(err)
+	}
+	return archive, nil
+}
+
+// ClaimPending claims up to limit of the oldest exports waiting to be produced that no other instance
+// has claimed in the last export.ClaimTimeout, and returns them.
+func (repository *ExportRepository) ClaimPending(ctx context.Context, limit int) ([]export.Export, error) {
+	claim := fmt.Sprintf(`
+	UPDATE user_exports SET claimed_at = $2
+	WHERE id IN (
+		SELECT id
+		FROM user_exports
+		WHERE status = 'pending' AND (claimed_at IS NULL OR claimed_at <= $3)
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING %s;
+	`, exportColumns)
+	now := time.Now()
+	rows, err := repository.Data.DB.QueryContext(ctx, claim, limit, now, now.Add(-export.ClaimTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var exports []export.Export
+	for rows.Next() {
+		var e export.Export
+		if err := scanExport(rows, &e); err != nil {
+			return nil, err
+		}
+		exports = append(exports, e)
+	}
+	return exports, rows.Err()
+}
+
+// Complete stores the archive of the pending export, which can be downloaded until expiresAt.
+func (repository *ExportRepository) Complete(ctx context.Context, id uint, archive []byte, expiresAt time.Time) error {
+	update := `
+	UPDATE user_exports SET status='ready', archive=$2, completed_at=$3, expires_at=$4
+	WHERE id=$1 AND status='pending';
+	`
+	return repository.finish(ctx, update, id, archive, time.Now(), expiresAt)
+}
+
+// Fail records why the pending export could not be produced. It is kept until expiresAt.
+func (repository *ExportRepository) Fail(ctx context.Context, id uint, reason string, expiresAt time.Time) error {
+	update := `
+	UPDATE user_exports SET status='failed', error=$2, completed_at=$3, expires_at=$4
+	WHERE id=$1 AND status='pending';
+	`
+	return repository.finish(ctx, update, id, reason, time.Now(), expiresAt)
+}
+
+// DeleteExpired removes the exports that expired before now and returns how many there were.
+func (repository *ExportRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := repository.Data.DB.ExecContext(ctx, `DELETE FROM user_exports WHERE expires_at <= $1;`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (repository *ExportRepository) finish(ctx context.Context, update string, id uint, args ...any) error {
+	result, err := repository.Data.DB.ExecContext(ctx, update, append([]any{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return notFound("pending export", id)
+	}
+	return nil
+}
+
+func scanExport(row scanner, e *export.Export) error {
+	return row.Scan(&e.ID, &e.UserID, &e.Status, &e.Error, &e.Size, &e.CreatedAt, &e.CompletedAt, &e.ExpiresAt)
+}
+
+func collectPosts(ctx context.Context, tx *sql.Tx, query string, userId uint) ([]export.Post, error) {
+	rows, err := tx.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	posts := []export.Post{}
+	for rows.Next() {
+		var p export.Post
+		if err := scanPost(rows, &p.Post, &p.DeletedAt); err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
+
+func collectComments(ctx context.Context, tx *sql.Tx, query string, userId uint) ([]comment.Comment, error) {
+	rows, err := tx.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	comments := []comment.Comment{}
+	for rows.Next() {
+		var c comment.Comment
+		err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.ParentID, &c.Depth, &c.Body, &c.CreatedAt, &c.UpdatedAt, &c.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		c.Deleted = c.DeletedAt != nil
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+func collectSessions(ctx context.Context, tx *sql.Tx, query string, userId uint) ([]export.Session, error) {
+	rows, err := tx.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sessions := []export.Session{}
+	for rows.Next() {
+		var s export.Session
+		if err := rows.Scan(&s.ID, &s.CreatedAt, &s.ExpiresAt, &s.RevokedAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func collectAudit(ctx context.Context, tx *sql.Tx, query string, userId uint) ([]export.AuditEntry, error) {
+	rows, err := tx.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries := []export.AuditEntry{}
+	for rows.Next() {
+		entry := export.AuditEntry{Action: export.ActionPostRevision}
+		if err := rows.Scan(&entry.PostID, &entry.Revision, &entry.Title, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
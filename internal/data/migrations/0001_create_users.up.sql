@@ -1,3 +1,4 @@
+-- IF NOT EXISTS keeps this migration compatible with databases created by the old schema.sql
 CREATE TABLE IF NOT EXISTS users (
   id SERIAL NOT NULL,
   first_name VARCHAR(150) NOT NULL,
@@ -10,14 +11,3 @@ CREATE TABLE IF NOT EXISTS users (
   updated_at TIMESTAMP,
   CONSTRAINT pk_users PRIMARY KEY(id)
 );
-
-CREATE TABLE IF NOT EXISTS posts (
-  id SERIAL NOT NULL,
-  user_id INT NOT NULL,
-  title VARCHAR(150) NOT NULL,
-  body TEXT NOT NULL,
-  created_at TIMESTAMP DEFAULT NOW(),
-  updated_at TIMESTAMP,
-  CONSTRAINT pk_posts PRIMARY KEY(id),
-  CONSTRAINT fk_posts_users FOREIGN KEY (user_id) REFERENCES users(id)
-);
\ No newline at end of file
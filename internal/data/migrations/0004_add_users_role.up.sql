@@ -0,0 +1,4 @@
+-- Roles: 'admin' manages everyone, 'editor' edits and publishes any post, 'author' only their own posts.
+-- The first admin must be promoted by hand: UPDATE users SET role = 'admin' WHERE username = '...';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'author'
+  CONSTRAINT chk_users_role CHECK (role IN ('admin', 'editor', 'author'));
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_posts_created_at_id;
+DROP INDEX IF EXISTS idx_users_created_at_id;
+
+ALTER TABLE posts ALTER COLUMN created_at DROP NOT NULL;
+ALTER TABLE users ALTER COLUMN created_at DROP NOT NULL;
@@ -0,0 +1,9 @@
+-- Keyset pagination sorts by (created_at, id), so created_at can't be NULL
+UPDATE users SET created_at = NOW() WHERE created_at IS NULL;
+ALTER TABLE users ALTER COLUMN created_at SET NOT NULL;
+
+UPDATE posts SET created_at = NOW() WHERE created_at IS NULL;
+ALTER TABLE posts ALTER COLUMN created_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_created_at_id ON users(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_posts_created_at_id ON posts(created_at, id);
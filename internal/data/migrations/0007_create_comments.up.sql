@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS comments (
+  id SERIAL NOT NULL,
+  post_id INT NOT NULL,
+  user_id INT NOT NULL,
+  parent_id INT,
+  depth INT NOT NULL DEFAULT 0,
+  body TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP,
+  deleted_at TIMESTAMP,
+  CONSTRAINT pk_comments PRIMARY KEY(id),
+  CONSTRAINT fk_comments_posts FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+  CONSTRAINT fk_comments_users FOREIGN KEY (user_id) REFERENCES users(id),
+  CONSTRAINT fk_comments_parent FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments(post_id, depth);
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments(parent_id);
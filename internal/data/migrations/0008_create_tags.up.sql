@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS tags (
+  id SERIAL NOT NULL,
+  name VARCHAR(50) NOT NULL,
+  slug VARCHAR(60) NOT NULL UNIQUE,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  CONSTRAINT pk_tags PRIMARY KEY(id)
+);
+
+CREATE TABLE IF NOT EXISTS post_tags (
+  post_id INT NOT NULL,
+  tag_id INT NOT NULL,
+  CONSTRAINT pk_post_tags PRIMARY KEY(post_id, tag_id),
+  CONSTRAINT fk_post_tags_posts FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+  CONSTRAINT fk_post_tags_tags FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_tags_tag_id ON post_tags(tag_id);
@@ -0,0 +1,8 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'draft'
+  CONSTRAINT chk_posts_status CHECK (status IN ('draft', 'scheduled', 'published', 'archived'));
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS published_at TIMESTAMP;
+
+-- Posts created before the lifecycle existed were public from the moment they were created
+UPDATE posts SET status = 'published', published_at = created_at;
+
+CREATE INDEX IF NOT EXISTS idx_posts_status_published_at ON posts(status, published_at);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS post_revisions (
+  id SERIAL NOT NULL,
+  post_id INT NOT NULL,
+  revision INT NOT NULL,
+  editor_id INT,
+  title VARCHAR(150) NOT NULL,
+  body TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  CONSTRAINT pk_post_revisions PRIMARY KEY(id),
+  CONSTRAINT uq_post_revisions_revision UNIQUE(post_id, revision),
+  CONSTRAINT fk_post_revisions_posts FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+  CONSTRAINT fk_post_revisions_users FOREIGN KEY (editor_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- The current content of the existing posts becomes their first revision
+INSERT INTO post_revisions (post_id, revision, editor_id, title, body, created_at)
+SELECT id, 1, user_id, title, body, COALESCE(updated_at, created_at)
+FROM posts;
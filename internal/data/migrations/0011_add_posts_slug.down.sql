@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS post_slug_history;
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS uq_posts_slug;
+ALTER TABLE posts DROP COLUMN IF EXISTS slug;
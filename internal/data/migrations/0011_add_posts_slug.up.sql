@@ -0,0 +1,28 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS slug VARCHAR(160);
+
+-- Existing posts get a plain ASCII slug from their title. The application transliterates the new ones,
+-- and the id is appended when two titles give the same slug.
+WITH generated AS (
+  SELECT id, COALESCE(NULLIF(left(trim(BOTH '-' FROM regexp_replace(lower(title), '[^a-z0-9]+', '-', 'g')), 150), ''), 'post') AS base
+  FROM posts
+), numbered AS (
+  SELECT id, base, ROW_NUMBER() OVER (PARTITION BY base ORDER BY id) AS n
+  FROM generated
+)
+UPDATE posts p SET slug = CASE WHEN numbered.n = 1 THEN numbered.base ELSE numbered.base || '-' || p.id END
+FROM numbered
+WHERE numbered.id = p.id;
+
+ALTER TABLE posts ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE posts ADD CONSTRAINT uq_posts_slug UNIQUE(slug);
+
+-- Former slugs of the posts, kept to redirect the old URLs
+CREATE TABLE IF NOT EXISTS post_slug_history (
+  slug VARCHAR(160) NOT NULL,
+  post_id INT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  CONSTRAINT pk_post_slug_history PRIMARY KEY(slug),
+  CONSTRAINT fk_post_slug_history_posts FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_slug_history_post_id ON post_slug_history(post_id);
@@ -0,0 +1,12 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS body_format VARCHAR(10) NOT NULL DEFAULT 'plain'
+  CONSTRAINT chk_posts_body_format CHECK (body_format IN ('markdown', 'plain', 'html'));
+
+-- Rendered body cached along with the source, and the table of contents of Markdown bodies
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS body_html TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS toc JSONB NOT NULL DEFAULT '[]';
+
+-- The existing posts are plain text, rendered with the same rules as render.Plain
+UPDATE posts SET body_html = '<p>' || replace(regexp_replace(
+    replace(replace(replace(replace(replace(replace(body, E'\r\n', E'\n'), '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '''', '&#39;'), '"', '&#34;'),
+    E'\n{2,}', '</p><p>', 'g'), E'\n', '<br>') || '</p>'
+WHERE body <> '';
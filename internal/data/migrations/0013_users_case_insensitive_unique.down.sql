@@ -0,0 +1,4 @@
+ALTER TABLE users ADD CONSTRAINT users_username_key UNIQUE(username);
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE(email);
+DROP INDEX IF EXISTS uq_users_username_lower;
+DROP INDEX IF EXISTS uq_users_email_lower;
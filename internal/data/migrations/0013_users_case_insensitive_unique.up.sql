@@ -0,0 +1,18 @@
+-- Usernames and emails are unique regardless of their case. The migration stops when existing accounts
+-- only differ by case, which must be merged by hand first.
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM users GROUP BY lower(username) HAVING COUNT(*) > 1) THEN
+    RAISE EXCEPTION 'there are usernames that only differ by case';
+  END IF;
+  IF EXISTS (SELECT 1 FROM users GROUP BY lower(email) HAVING COUNT(*) > 1) THEN
+    RAISE EXCEPTION 'there are emails that only differ by case';
+  END IF;
+END $$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_users_username_lower ON users (lower(username));
+CREATE UNIQUE INDEX IF NOT EXISTS uq_users_email_lower ON users (lower(email));
+
+-- The case sensitive constraints are covered by the new indexes
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_username_key;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
@@ -0,0 +1,4 @@
+-- Version of the row, incremented by every change so that updates can be made only when the row
+-- has not been changed since it was read
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
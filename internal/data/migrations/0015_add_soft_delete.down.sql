@@ -0,0 +1,10 @@
+DELETE FROM comments WHERE user_id IS NULL;
+ALTER TABLE comments DROP CONSTRAINT IF EXISTS fk_comments_users;
+ALTER TABLE comments ADD CONSTRAINT fk_comments_users FOREIGN KEY (user_id) REFERENCES users(id);
+ALTER TABLE comments ALTER COLUMN user_id SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_users_deleted_at;
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,5 @@
+-- The ghost account is kept while it owns anonymized posts or comments
+DELETE FROM users u
+WHERE u.username = '[deleted]'
+  AND NOT EXISTS (SELECT 1 FROM posts p WHERE p.user_id = u.id)
+  AND NOT EXISTS (SELECT 1 FROM comments c WHERE c.user_id = u.id);
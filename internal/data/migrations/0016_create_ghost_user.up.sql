@@ -0,0 +1,5 @@
+-- The ghost account owns the posts and comments of the users deleted with the 'anonymize' policy. Its
+-- username can't be registered and its password can't match any password, so nobody can log in as it.
+INSERT INTO users (first_name, last_name, username, password, email, picture, role, created_at)
+VALUES ('Deleted', 'user', '[deleted]', '!', 'deleted@users.invalid', '', 'author', NOW())
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,18 @@
+-- Archives of the personal data of the users that are too large to be produced while the request waits
+CREATE TABLE IF NOT EXISTS user_exports (
+  id SERIAL NOT NULL,
+  user_id INT NOT NULL,
+  status VARCHAR(16) NOT NULL DEFAULT 'pending',
+  error TEXT NOT NULL DEFAULT '',
+  archive BYTEA,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  completed_at TIMESTAMP,
+  expires_at TIMESTAMP,
+  CONSTRAINT pk_user_exports PRIMARY KEY(id),
+  CONSTRAINT fk_user_exports_users FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT ck_user_exports_status CHECK (status IN ('pending', 'ready', 'failed'))
+);
+
+-- A user has at most one export waiting to be produced
+CREATE UNIQUE INDEX IF NOT EXISTS uq_user_exports_pending ON user_exports(user_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_user_exports_user_id ON user_exports(user_id, id);
@@ -0,0 +1,2 @@
+-- The former slugs are not kept, so the transliterated ones stay
+SELECT 1;
@@ -0,0 +1,54 @@
+-- Tags created before the slugs were transliterated keep the letters of their name in their slug, so adding
+-- them again would create a duplicate. They get the slug the application computes now, which is their
+-- slug with the same transliterations, truncated to the length of the column. Tags that end up with the
+-- same slug are merged into the oldest one.
+CREATE TEMPORARY TABLE tag_slugs ON COMMIT DROP AS
+WITH transliterations (letter, replacement) AS (
+  VALUES
+    ('à', 'a'), ('á', 'a'), ('â', 'a'), ('ã', 'a'), ('ä', 'a'), ('å', 'a'), ('ā', 'a'), ('ă', 'a'), ('ą', 'a'), ('æ', 'ae'),
+    ('ç', 'c'), ('ć', 'c'), ('ĉ', 'c'), ('ċ', 'c'), ('č', 'c'), ('ď', 'd'), ('đ', 'd'), ('ð', 'd'), ('è', 'e'), ('é', 'e'),
+    ('ê', 'e'), ('ë', 'e'), ('ē', 'e'), ('ĕ', 'e'), ('ė', 'e'), ('ę', 'e'), ('ě', 'e'), ('ĝ', 'g'), ('ğ', 'g'), ('ġ', 'g'),
+    ('ģ', 'g'), ('ĥ', 'h'), ('ħ', 'h'), ('ì', 'i'), ('í', 'i'), ('î', 'i'), ('ï', 'i'), ('ĩ', 'i'), ('ī', 'i'), ('ĭ', 'i'),
+    ('į', 'i'), ('ı', 'i'), ('ĳ', 'ij'), ('ĵ', 'j'), ('ķ', 'k'), ('ĺ', 'l'), ('ļ', 'l'), ('ľ', 'l'), ('ŀ', 'l'), ('ł', 'l'),
+    ('ñ', 'n'), ('ń', 'n'), ('ņ', 'n'), ('ň', 'n'), ('ŋ', 'n'), ('ò', 'o'), ('ó', 'o'), ('ô', 'o'), ('õ', 'o'), ('ö', 'o'),
+    ('ø', 'o'), ('ō', 'o'), ('ŏ', 'o'), ('ő', 'o'), ('œ', 'oe'), ('ŕ', 'r'), ('ŗ', 'r'), ('ř', 'r'), ('ś', 's'), ('ŝ', 's'),
+    ('ş', 's'), ('š', 's'), ('ș', 's'), ('ß', 'ss'), ('ţ', 't'), ('ť', 't'), ('ŧ', 't'), ('ț', 't'), ('þ', 'th'), ('ù', 'u'),
+    ('ú', 'u'), ('û', 'u'), ('ü', 'u'), ('ũ', 'u'), ('ū', 'u'), ('ŭ', 'u'), ('ů', 'u'), ('ű', 'u'), ('ų', 'u'), ('ŵ', 'w'),
+    ('ý', 'y'), ('ÿ', 'y'), ('ŷ', 'y'), ('ź', 'z'), ('ż', 'z'), ('ž', 'z'), ('а', 'a'), ('б', 'b'), ('в', 'v'), ('г', 'g'),
+    ('ґ', 'g'), ('д', 'd'), ('е', 'e'), ('ё', 'yo'), ('є', 'ye'), ('ж', 'zh'), ('з', 'z'), ('и', 'i'), ('і', 'i'), ('ї', 'yi'),
+    ('й', 'y'), ('к', 'k'), ('л', 'l'), ('м', 'm'), ('н', 'n'), ('о', 'o'), ('п', 'p'), ('р', 'r'), ('с', 's'), ('т', 't'),
+    ('у', 'u'), ('ў', 'u'), ('ф', 'f'), ('х', 'kh'), ('ц', 'ts'), ('ч', 'ch'), ('ш', 'sh'), ('щ', 'shch'), ('ъ', ''), ('ы', 'y'),
+    ('ь', ''), ('э', 'e'), ('ю', 'yu'), ('я', 'ya'), ('α', 'a'), ('ά', 'a'), ('β', 'v'), ('γ', 'g'), ('δ', 'd'), ('ε', 'e'),
+    ('έ', 'e'), ('ζ', 'z'), ('η', 'i'), ('ή', 'i'), ('θ', 'th'), ('ι', 'i'), ('ί', 'i'), ('ϊ', 'i'), ('ΐ', 'i'), ('κ', 'k'),
+    ('λ', 'l'), ('μ', 'm'), ('ν', 'n'), ('ξ', 'x'), ('ο', 'o'), ('ό', 'o'), ('π', 'p'), ('ρ', 'r'), ('σ', 's'), ('ς', 's'),
+    ('τ', 't'), ('υ', 'y'), ('ύ', 'y'), ('ϋ', 'y'), ('ΰ', 'y'), ('φ', 'f'), ('χ', 'ch'), ('ψ', 'ps'), ('ω', 'o'), ('ώ', 'o')
+), letters AS (
+  SELECT t.id, l.n, COALESCE(tr.replacement, l.letter) AS replacement
+  FROM tags t
+  CROSS JOIN LATERAL regexp_split_to_table(t.slug, '') WITH ORDINALITY AS l(letter, n)
+  LEFT JOIN transliterations tr ON tr.letter = l.letter
+), transliterated AS (
+  SELECT id, trim(BOTH '-' FROM regexp_replace(string_agg(replacement, '' ORDER BY n), '-{2,}', '-', 'g')) AS slug
+  FROM letters
+  GROUP BY id
+), truncated AS (
+  SELECT id, CASE WHEN octet_length(slug) <= 60 THEN slug
+    ELSE trim(TRAILING '-' FROM regexp_replace(left(slug, 60), '-[^-]*$', '')) END AS slug
+  FROM transliterated
+)
+SELECT id, slug, MIN(id) OVER (PARTITION BY slug) AS kept_id
+FROM truncated
+WHERE slug <> '';
+
+INSERT INTO post_tags (post_id, tag_id)
+SELECT pt.post_id, s.kept_id
+FROM post_tags pt
+JOIN tag_slugs s ON s.id = pt.tag_id
+WHERE s.id <> s.kept_id
+ON CONFLICT DO NOTHING;
+
+DELETE FROM tags WHERE id IN (SELECT id FROM tag_slugs WHERE id <> kept_id);
+
+UPDATE tags t SET slug = s.slug
+FROM tag_slugs s
+WHERE s.id = t.id AND t.slug <> s.slug;
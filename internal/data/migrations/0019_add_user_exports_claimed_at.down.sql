@@ -0,0 +1 @@
+ALTER TABLE user_exports DROP COLUMN IF EXISTS claimed_at;
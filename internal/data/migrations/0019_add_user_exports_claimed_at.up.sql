@@ -0,0 +1,3 @@
+-- Time an instance claimed the pending export to produce it, so that other instances leave it alone
+-- until the claim expires
+ALTER TABLE user_exports ADD COLUMN IF NOT EXISTS claimed_at TIMESTAMP;
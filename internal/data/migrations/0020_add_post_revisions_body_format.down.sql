@@ -0,0 +1 @@
+ALTER TABLE post_revisions DROP COLUMN IF EXISTS body_format;
@@ -0,0 +1,8 @@
+-- Format of the body of the revision, so that a restored revision is rendered the way it was written.
+-- The existing revisions take the current format of their post, which is how they were restored until now.
+ALTER TABLE post_revisions ADD COLUMN IF NOT EXISTS body_format VARCHAR(10) NOT NULL DEFAULT 'plain'
+  CONSTRAINT chk_post_revisions_body_format CHECK (body_format IN ('markdown', 'plain', 'html'));
+
+UPDATE post_revisions r SET body_format = p.body_format
+FROM posts p
+WHERE p.id = r.post_id;
@@ -0,0 +1,45 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/page"
+)
+
+// paginate adds to the query the keyset condition when the request has a cursor and returns the
+// ORDER BY and LIMIT clauses of the page. Listings are sorted from newest to oldest. One more row
+// than the limit is fetched so that page.New can tell whether there is a next page.
+func paginate(req page.Request, prefix string, conditions []string, args []any) ([]string, string, []any) {
+	if req.Cursor != nil {
+		operator, direction := "<", "DESC"
+		if req.Cursor.Backward {
+			operator, direction = ">", "ASC"
+		}
+		args = append(args, req.Cursor.CreatedAt, req.Cursor.ID)
+		conditions = append(conditions, fmt.Sprintf("(%[1]screated_at, %[1]sid) %[2]s ($%[3]d, $%[4]d)",
+			prefix, operator, len(args)-1, len(args)))
+		args = append(args, req.Limit+1)
+		return conditions, fmt.Sprintf("ORDER BY %[1]screated_at %[2]s, %[1]sid %[2]s LIMIT $%[3]d",
+			prefix, direction, len(args)), args
+	}
+
+	args = append(args, req.Limit+1, req.Offset)
+	return conditions, fmt.Sprintf("ORDER BY %[1]screated_at DESC, %[1]sid DESC LIMIT $%[2]d OFFSET $%[3]d",
+		prefix, len(args)-1, len(args)), args
+}
+
+// paginateSorted returns the ORDER BY and LIMIT clauses of a page of a listing with a custom sort,
+// which can only be paginated by offset.
+func paginateSorted(req page.Request, order string, args []any) (string, []any) {
+	args = append(args, req.Limit+1, req.Offset)
+	return fmt.Sprintf("ORDER BY %s LIMIT $%d OFFSET $%d", order, len(args)-1, len(args)), args
+}
+
+// where joins the conditions of a query into a WHERE clause.
+func where(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
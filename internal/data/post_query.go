@@ -0,0 +1,75 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/post"
+)
+
+// postSortColumns whitelists the columns that listings of posts can be sorted by
+var postSortColumns = map[string]string{
+	"id":         "p.id",
+	"title":      "p.title",
+	"created_at": "p.created_at",
+	"updated_at": "p.updated_at",
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// postFilters compiles the filters of the query into parameterized conditions on the posts table, aliased as 'p'.
+// Deleted posts are always left out.
+func postFilters(query post.Query) ([]string, []any) {
+	conditions := []string{"p.deleted_at IS NULL"}
+	var args []any
+	addCondition := func(format string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if query.Status != "" {
+		addCondition("p.status = $%d", query.Status)
+	}
+	if query.AuthorID != nil {
+		addCondition("p.user_id = $%d", *query.AuthorID)
+	}
+	if query.AuthorUsername != "" {
+		addCondition("p.user_id = (SELECT id FROM users WHERE lower(username) = lower($%d) AND deleted_at IS NULL)", query.AuthorUsername)
+	}
+	if query.TagSlug != "" {
+		addCondition("EXISTS (SELECT 1 FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.post_id = p.id AND t.slug = $%d)", query.TagSlug)
+	}
+	if query.CreatedAfter != nil {
+		addCondition("p.created_at > $%d", *query.CreatedAfter)
+	}
+	if query.CreatedBefore != nil {
+		addCondition("p.created_at < $%d", *query.CreatedBefore)
+	}
+	if query.TitleContains != "" {
+		addCondition("p.title ILIKE '%%' || $%d || '%%'", likeEscaper.Replace(query.TitleContains))
+	}
+	return conditions, args
+}
+
+// postOrder compiles the sort of the query into an ORDER BY list. The id is always added last
+// so that the order is deterministic.
+func postOrder(query post.Query) (string, error) {
+	var order []string
+	sortedById := false
+	for _, field := range query.Sort {
+		column, ok := postSortColumns[field.Field]
+		if !ok {
+			return "", fmt.Errorf("the posts can't be sorted by '%s'", field.Field)
+		}
+		direction := "ASC"
+		if field.Desc {
+			direction = "DESC"
+		}
+		order = append(order, column+" "+direction)
+		sortedById = sortedById || field.Field == "id"
+	}
+	if !sortedById {
+		order = append(order, "p.id DESC")
+	}
+	return strings.Join(order, ", "), nil
+}
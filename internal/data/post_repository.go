@@ -2,9 +2,17 @@ package data
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
 	"time"
 
+	"github.com/cortzero/go-postgres-blog/internal/model/page"
 	"github.com/cortzero/go-postgres-blog/internal/model/post"
+	"github.com/cortzero/go-postgres-blog/internal/model/tag"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+	"github.com/cortzero/go-postgres-blog/internal/service/slug"
+	"github.com/lib/pq"
 )
 
 type PostRepository struct {
@@ -17,14 +25,35 @@ func NewPostRepository(connection *Data) *PostRepository {
 	}
 }
 
-func (repository *PostRepository) GetAll(ctx context.Context) ([]post.Post, error) {
-	query := `
-	SELECT id, title, body, user_id, created_at, updated_at
-	FROM posts;
-	`
-	rows, err := repository.Data.DB.QueryContext(ctx, query)
+func (repository *PostRepository) GetAll(ctx context.Context, query post.Query) (page.Page[post.Post], error) {
+	conditions, args := postFilters(query)
+
+	var total int
+	count := fmt.Sprintf(`SELECT COUNT(*) FROM posts p %s;`, where(conditions))
+	if err := repository.Data.DB.QueryRowContext(ctx, count, args...).Scan(&total); err != nil {
+		return page.Page[post.Post]{}, err
+	}
+
+	var orderAndLimit string
+	if len(query.Sort) > 0 {
+		order, err := postOrder(query)
+		if err != nil {
+			return page.Page[post.Post]{}, err
+		}
+		orderAndLimit, args = paginateSorted(query.Page, order, args)
+	} else {
+		conditions, orderAndLimit, args = paginate(query.Page, "p.", conditions, args)
+	}
+
+	selectQuery := fmt.Sprintf(`
+	SELECT %s
+	FROM posts p
+	%s
+	%s;
+	`, postColumns, where(conditions), orderAndLimit)
+	rows, err := repository.Data.DB.QueryContext(ctx, selectQuery, args...)
 	if err != nil {
-		return nil, err
+		return page.Page[post.Post]{}, err
 	}
 
 	defer rows.Close()
@@ -32,92 +61,172 @@ func (repository *PostRepository) GetAll(ctx context.Context) ([]post.Post, erro
 	var posts []post.Post
 	for rows.Next() {
 		var p post.Post
-		rows.Scan(&p.ID, &p.Title, &p.Body, &p.UserID, &p.CreatedAt, &p.UpdatedAt)
+		err := scanPost(rows, &p)
+		if err != nil {
+			return page.Page[post.Post]{}, err
+		}
 		posts = append(posts, p)
 	}
-	return posts, nil
+	if err := rows.Err(); err != nil {
+		return page.Page[post.Post]{}, err
+	}
+	return page.New(query.Page, posts, total, postCursor), nil
 }
 
 func (repository *PostRepository) GetById(ctx context.Context, id uint) (post.Post, error) {
-	query := `
-	SELECT id, user_id, title, body, created_at, COALESCE(updated_at, '0001-01-01T00:00:00Z')
-	FROM posts
-	WHERE id = $1;
-	`
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM posts p
+	WHERE p.id = $1 AND p.deleted_at IS NULL;
+	`, postColumns)
 	row := repository.Data.DB.QueryRowContext(ctx, query, id)
 	var p post.Post
-	err := row.Scan(&p.ID, &p.UserID, &p.Title, &p.Body, &p.CreatedAt, &p.UpdatedAt)
+	err := scanPost(row, &p)
 	if err != nil {
-		return post.Post{}, err
+		return post.Post{}, translateError(err)
 	}
 	return p, nil
 }
 
-func (repository *PostRepository) GetByUser(ctx context.Context, userId uint) ([]post.Post, error) {
-	query := `
-	SELECT id, title, body, user_id, created_at, updated_at
-	FROM posts
-	WHERE user_id=$1;
+// GetByUser returns a page of the posts written by the user with the status, or with any status when
+// it is empty, newest first.
+func (repository *PostRepository) GetByUser(ctx context.Context, userId uint, status post.Status, req page.Request) (page.Page[post.Post], error) {
+	return repository.GetAll(ctx, post.Query{
+		AuthorID: &userId,
+		Status:   status,
+		Page:     req,
+	})
+}
+
+// Create stores the post along with its slug, its tags and its first revision in a single transaction.
+func (repository *PostRepository) Create(ctx context.Context, post *post.Post) error {
+	insert := `
+	INSERT INTO posts (user_id, title, slug, body, body_format, body_html, toc, status, published_at, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	RETURNING id, version;
 	`
-	rows, err := repository.Data.DB.QueryContext(ctx, query)
+	toc, err := json.Marshal(post.TOC)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	defer rows.Close()
+	tx, err := repository.Data.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
 
-	var posts []post.Post
-	for rows.Next() {
-		var p post.Post
-		rows.Scan(&p.ID, &p.Title, &p.Body, &p.UserID, &p.CreatedAt, &p.UpdatedAt)
-		posts = append(posts, p)
+	defer tx.Rollback()
+
+	post.Slug, err = newPostSlug(ctx, tx, post.Title, 0)
+	if err != nil {
+		return err
+	}
+
+	row := tx.QueryRowContext(ctx, insert, post.UserID, post.Title, post.Slug, post.Body, post.BodyFormat, post.BodyHTML, toc,
+		post.Status, post.PublishedAt, time.Now(), nil)
+	err = row.Scan(&post.ID, &post.Version)
+	if err != nil {
+		return translateError(err)
+	}
+
+	if err := setPostTags(ctx, tx, post.ID, post.Tags); err != nil {
+		return err
 	}
-	return posts, nil
+	if err := addPostRevision(ctx, tx, post.ID, post.UserID, post.Title, post.Body, post.BodyFormat); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (repository *PostRepository) Create(ctx context.Context, post *post.Post) error {
-	insert := `
-	INSERT INTO posts (user_id, title, body, created_at, updated_at)
-	VALUES ($1, $2, $3, $4, $5)
-	RETURNING id;
+// Update changes the post, renews its slug when the title changes, records the change as a new revision
+// made by the editor and, when post.Tags is not nil, replaces its tags in a single transaction. The post
+// is only changed while it is at post.Version, which is then incremented.
+func (repository *PostRepository) Update(ctx context.Context, id uint, post post.Post, editorId uint) error {
+	update := `
+	UPDATE posts SET title=$1, body=$2, body_format=$3, body_html=$4, toc=$5, updated_at=$6, version=version+1
+	WHERE id=$7 AND version=$8 AND deleted_at IS NULL;
 	`
-	stmt, err := repository.Data.DB.PrepareContext(ctx, insert)
+	toc, err := json.Marshal(post.TOC)
 	if err != nil {
 		return err
 	}
 
-	defer stmt.Close()
+	tx, err := repository.Data.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
 
-	row := stmt.QueryRowContext(ctx, post.UserID, post.Title, post.Body, time.Now(), nil)
-	err = row.Scan(&post.ID)
+	result, err := tx.ExecContext(ctx, update, post.Title, post.Body, post.BodyFormat, post.BodyHTML, toc, post.UpdatedAt, id, post.Version)
+	if err != nil {
+		return translateError(err)
+	}
+	rows, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
-	return nil
+	if rows == 0 {
+		return notChanged(ctx, tx, "posts", "post", id, post.Version)
+	}
+
+	if err := updatePostSlug(ctx, tx, id, post.Title); err != nil {
+		return err
+	}
+
+	if err := addPostRevision(ctx, tx, id, editorId, post.Title, post.Body, post.BodyFormat); err != nil {
+		return err
+	}
+
+	if post.Tags != nil {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM post_tags WHERE post_id=$1;`, id); err != nil {
+			return err
+		}
+		if err := setPostTags(ctx, tx, id, post.Tags); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
-func (repository *PostRepository) Update(ctx context.Context, id uint, post post.Post) error {
+// UpdateStatus changes the status of the post while it is at the version, which is then incremented.
+func (repository *PostRepository) UpdateStatus(ctx context.Context, id uint, version int, status post.Status, publishedAt *time.Time) error {
 	update := `
-	UPDATE posts SET title=$1, body=$2, updated_at=$3
-	WHERE id=$4;
+	UPDATE posts SET status=$1, published_at=$2, updated_at=$3, version=version+1
+	WHERE id=$4 AND version=$5 AND deleted_at IS NULL;
 	`
-	stmt, err := repository.Data.DB.PrepareContext(ctx, update)
+	result, err := repository.Data.DB.ExecContext(ctx, update, status, publishedAt, time.Now(), id, version)
 	if err != nil {
 		return err
 	}
-
-	defer stmt.Close()
-
-	_, err = stmt.ExecContext(ctx, post.Title, post.Body, post.UpdatedAt, id)
+	rows, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if rows == 0 {
+		return notChanged(ctx, repository.Data.DB, "posts", "post", id, version)
+	}
 	return nil
 }
 
-func (repository PostRepository) Delete(ctx context.Context, id uint) error {
+// PublishDue publishes the scheduled posts whose publication time has come and returns how many there were.
+func (repository *PostRepository) PublishDue(ctx context.Context, now time.Time) (int64, error) {
+	update := `
+	UPDATE posts SET status='published', updated_at=$1, version=version+1
+	WHERE status='scheduled' AND published_at <= $1 AND deleted_at IS NULL;
+	`
+	result, err := repository.Data.DB.ExecContext(ctx, update, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Delete moves the post to the trash while it is at the version.
+func (repository PostRepository) Delete(ctx context.Context, id uint, version int) error {
 	delete := `
-	DELETE FROM posts WHERE id=$1;
+	UPDATE posts SET deleted_at=$3, version=version+1
+	WHERE id=$1 AND version=$2 AND deleted_at IS NULL;
 	`
 	stmt, err := repository.Data.DB.PrepareContext(ctx, delete)
 	if err != nil {
@@ -126,9 +235,96 @@ func (repository PostRepository) Delete(ctx context.Context, id uint) error {
 
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, id)
+	result, err := stmt.ExecContext(ctx, id, version, time.Now())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return notChanged(ctx, repository.Data.DB, "posts", "post", id, version)
+	}
+	return nil
+}
+
+// Restore takes the post out of the trash. It can't be restored while its author is deleted.
+func (repository *PostRepository) Restore(ctx context.Context, id uint) error {
+	query := `
+	SELECT u.deleted_at IS NOT NULL
+	FROM posts p
+	JOIN users u ON u.id = p.user_id
+	WHERE p.id = $1 AND p.deleted_at IS NOT NULL
+	FOR UPDATE OF p;
+	`
+	tx, err := repository.Data.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+
+	defer tx.Rollback()
+
+	var authorDeleted bool
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&authorDeleted); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return notFound("deleted post", id)
+		}
+		return err
+	}
+	if authorDeleted {
+		return post.ErrAuthorDeleted
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE posts SET deleted_at=NULL, version=version+1 WHERE id=$1;`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func postCursor(p post.Post) page.Cursor {
+	return page.Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
+}
+
+// postColumns are the columns of a post selected from the posts table aliased as 'p'. The tags are
+// aggregated by the same query so that listing posts doesn't need a query per post.
+const postColumns = `p.id, p.user_id, p.title, p.slug, p.body, p.body_format, p.body_html, p.toc, p.status, p.published_at, p.version, p.created_at, COALESCE(p.updated_at, '0001-01-01T00:00:00Z'),
+	COALESCE((SELECT array_agg(t.name ORDER BY t.name) FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.post_id = p.id), '{}')`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanPost scans a row selected with postColumns, followed by the extra destinations.
+func scanPost(row scanner, p *post.Post, extra ...any) error {
+	var toc []byte
+	dest := []any{&p.ID, &p.UserID, &p.Title, &p.Slug, &p.Body, &p.BodyFormat, &p.BodyHTML, &toc,
+		&p.Status, &p.PublishedAt, &p.Version, &p.CreatedAt, &p.UpdatedAt, pq.Array(&p.Tags)}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	return json.Unmarshal(toc, &p.TOC)
+}
+
+// setPostTags attaches the tags to the post, creating the ones that don't exist yet.
+func setPostTags(ctx context.Context, tx *sql.Tx, postId uint, names []string) error {
+	upsert := `
+	INSERT INTO tags (name, slug) VALUES ($1, $2)
+	ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+	RETURNING id;
+	`
+	attach := `
+	INSERT INTO post_tags (post_id, tag_id) VALUES ($1, $2)
+	ON CONFLICT DO NOTHING;
+	`
+	for _, name := range names {
+		var tagId uint
+		if err := tx.QueryRowContext(ctx, upsert, name, slug.Truncate(slug.Make(name), tag.MaxSlugLength)).Scan(&tagId); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, attach, postId, tagId); err != nil {
+			return err
+		}
+	}
 	return nil
 }
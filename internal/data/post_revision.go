@@ -0,0 +1,75 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/post"
+)
+
+// GetRevisions returns the revisions of the post, newest first.
+func (repository *PostRepository) GetRevisions(ctx context.Context, postId uint) ([]post.Revision, error) {
+	query := `
+	SELECT id, post_id, revision, editor_id, title, body, body_format, created_at
+	FROM post_revisions
+	WHERE post_id=$1
+	ORDER BY revision DESC;
+	`
+	rows, err := repository.Data.DB.QueryContext(ctx, query, postId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revisions := []post.Revision{}
+	for rows.Next() {
+		var revision post.Revision
+		if err := scanRevision(rows, &revision); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+func (repository *PostRepository) GetRevision(ctx context.Context, postId uint, number int) (post.Revision, error) {
+	query := `
+	SELECT id, post_id, revision, editor_id, title, body, body_format, created_at
+	FROM post_revisions
+	WHERE post_id=$1 AND revision=$2;
+	`
+	row := repository.Data.DB.QueryRowContext(ctx, query, postId, number)
+	var revision post.Revision
+	if err := scanRevision(row, &revision); err != nil {
+		return post.Revision{}, translateError(err)
+	}
+	return revision, nil
+}
+
+// addPostRevision appends the next revision of the post. It must run in the transaction that
+// changed the post, whose row lock keeps concurrent updates from taking the same number.
+func addPostRevision(ctx context.Context, tx *sql.Tx, postId uint, editorId uint, title string, body string, bodyFormat post.BodyFormat) error {
+	insert := `
+	INSERT INTO post_revisions (post_id, revision, editor_id, title, body, body_format, created_at)
+	SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4, $5, $6
+	FROM post_revisions
+	WHERE post_id=$1;
+	`
+	_, err := tx.ExecContext(ctx, insert, postId, editorId, title, body, bodyFormat, time.Now())
+	return err
+}
+
+func scanRevision(row scanner, revision *post.Revision) error {
+	var editorId sql.NullInt64
+	err := row.Scan(&revision.ID, &revision.PostID, &revision.Number, &editorId, &revision.Title, &revision.Body, &revision.BodyFormat, &revision.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if editorId.Valid {
+		id := uint(editorId.Int64)
+		revision.EditorID = &id
+	}
+	return nil
+}
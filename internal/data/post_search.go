@@ -0,0 +1,78 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/page"
+	"github.com/cortzero/go-postgres-blog/internal/model/post"
+)
+
+// The highlights are delimited with private use characters so that the text can be HTML-escaped
+// before the delimiters are replaced by <mark> tags.
+const (
+	highlightStart = "\uE000"
+	highlightStop  = "\uE001"
+)
+
+var (
+	highlightOptions  = `StartSel="` + highlightStart + `", StopSel="` + highlightStop + `", HighlightAll=true`
+	snippetOptions    = `StartSel="` + highlightStart + `", StopSel="` + highlightStop + `", MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=" … "`
+	highlightReplacer = strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>")
+)
+
+// Search looks for the published posts matching the terms, which use the web search syntax ("quoted phrases",
+// 'or', -excluded). The results are sorted by relevance and can only be paginated by offset.
+func (repository *PostRepository) Search(ctx context.Context, terms string, req page.Request) (page.Page[post.SearchResult], error) {
+	var total int
+	count := `
+	SELECT COUNT(*)
+	FROM posts p
+	WHERE p.status = 'published' AND p.deleted_at IS NULL AND p.search_vector @@ websearch_to_tsquery('english', $1);
+	`
+	if err := repository.Data.DB.QueryRowContext(ctx, count, terms).Scan(&total); err != nil {
+		return page.Page[post.SearchResult]{}, err
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %s,
+		ts_rank_cd(p.search_vector, q) AS rank,
+		ts_headline('english', p.title, q, $2),
+		ts_headline('english', p.body, q, $3)
+	FROM posts p, websearch_to_tsquery('english', $1) q
+	WHERE p.status = 'published' AND p.deleted_at IS NULL AND p.search_vector @@ q
+	ORDER BY rank DESC, p.id DESC
+	LIMIT $4 OFFSET $5;
+	`, postColumns)
+	rows, err := repository.Data.DB.QueryContext(ctx, query, terms, highlightOptions, snippetOptions, req.Limit+1, req.Offset)
+	if err != nil {
+		return page.Page[post.SearchResult]{}, err
+	}
+
+	defer rows.Close()
+
+	var results []post.SearchResult
+	for rows.Next() {
+		var r post.SearchResult
+		err := scanPost(rows, &r.Post, &r.Rank, &r.HighlightedTitle, &r.Snippet)
+		if err != nil {
+			return page.Page[post.SearchResult]{}, err
+		}
+		r.HighlightedTitle = highlight(r.HighlightedTitle)
+		r.Snippet = highlight(r.Snippet)
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return page.Page[post.SearchResult]{}, err
+	}
+	return page.New(req, results, total, func(r post.SearchResult) page.Cursor {
+		return postCursor(r.Post)
+	}), nil
+}
+
+// highlight escapes the text returned by ts_headline and marks the matching terms.
+func highlight(text string) string {
+	return highlightReplacer.Replace(html.EscapeString(text))
+}
@@ -0,0 +1,122 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/post"
+	"github.com/cortzero/go-postgres-blog/internal/service/slug"
+)
+
+// maxSlugLength leaves room in the slug column for the suffix added on collisions
+const maxSlugLength = 150
+
+func (repository *PostRepository) GetBySlug(ctx context.Context, slug string) (post.Post, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM posts p
+	WHERE p.slug = $1 AND p.deleted_at IS NULL;
+	`, postColumns)
+	row := repository.Data.DB.QueryRowContext(ctx, query, slug)
+	var p post.Post
+	if err := scanPost(row, &p); err != nil {
+		return post.Post{}, translateError(err)
+	}
+	return p, nil
+}
+
+// GetByFormerSlug returns the post that used to have the slug before its title changed.
+func (repository *PostRepository) GetByFormerSlug(ctx context.Context, slug string) (post.Post, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM posts p
+	WHERE p.id = (SELECT post_id FROM post_slug_history WHERE slug = $1) AND p.deleted_at IS NULL;
+	`, postColumns)
+	row := repository.Data.DB.QueryRowContext(ctx, query, slug)
+	var p post.Post
+	if err := scanPost(row, &p); err != nil {
+		return post.Post{}, translateError(err)
+	}
+	return p, nil
+}
+
+// newPostSlug returns a slug for the title that no other post uses or used, adding a numeric
+// suffix when the plain slug is taken. A postId of 0 means that the post is new.
+func newPostSlug(ctx context.Context, tx *sql.Tx, title string, postId uint) (string, error) {
+	base := postSlugBase(title)
+	query := `
+	SELECT slug FROM posts WHERE (slug = $1 OR slug LIKE $2) AND id <> $3
+	UNION
+	SELECT slug FROM post_slug_history WHERE (slug = $1 OR slug LIKE $2) AND post_id <> $3;
+	`
+	rows, err := tx.QueryContext(ctx, query, base, likeEscaper.Replace(base)+"-%", postId)
+	if err != nil {
+		return "", err
+	}
+
+	defer rows.Close()
+
+	taken := make(map[string]bool)
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return "", err
+		}
+		taken[s] = true
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	candidate := base
+	for n := 2; taken[candidate]; n++ {
+		candidate = base + "-" + strconv.Itoa(n)
+	}
+	return candidate, nil
+}
+
+// updatePostSlug gives the post a new slug when its title no longer matches the current one,
+// keeping the former slug in the history so that the old URLs can be redirected.
+func updatePostSlug(ctx context.Context, tx *sql.Tx, postId uint, title string) error {
+	var current string
+	if err := tx.QueryRowContext(ctx, `SELECT slug FROM posts WHERE id = $1 FOR UPDATE;`, postId).Scan(&current); err != nil {
+		return err
+	}
+
+	if current == postSlugBase(title) {
+		return nil
+	}
+
+	// A slug with a collision suffix is kept as long as the title still gives it
+	newSlug, err := newPostSlug(ctx, tx, title, postId)
+	if err != nil {
+		return err
+	}
+	if newSlug == current {
+		return nil
+	}
+
+	history := `
+	INSERT INTO post_slug_history (slug, post_id) VALUES ($1, $2)
+	ON CONFLICT (slug) DO NOTHING;
+	`
+	if _, err := tx.ExecContext(ctx, history, current, postId); err != nil {
+		return err
+	}
+	// Going back to a former slug takes it out of the history
+	if _, err := tx.ExecContext(ctx, `DELETE FROM post_slug_history WHERE slug = $1 AND post_id = $2;`, newSlug, postId); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE posts SET slug = $1 WHERE id = $2;`, newSlug, postId)
+	return err
+}
+
+func postSlugBase(title string) string {
+	base := slug.Truncate(slug.Make(title), maxSlugLength)
+	if base == "" {
+		return "post"
+	}
+	return base
+}
@@ -0,0 +1,94 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/auth"
+)
+
+type RefreshTokenRepository struct {
+	Data *Data
+}
+
+func NewRefreshTokenRepository(connection *Data) *RefreshTokenRepository {
+	return &RefreshTokenRepository{
+		Data: connection,
+	}
+}
+
+func (repository *RefreshTokenRepository) Create(ctx context.Context, token *auth.RefreshToken) error {
+	insert := `
+	INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id;
+	`
+	row := repository.Data.DB.QueryRowContext(ctx, insert,
+		token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt, token.CreatedAt,
+	)
+	return row.Scan(&token.ID)
+}
+
+func (repository *RefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (auth.RefreshToken, error) {
+	query := `
+	SELECT id, user_id, family_id, token_hash, expires_at, revoked_at, replaced_by, created_at
+	FROM refresh_tokens
+	WHERE token_hash = $1;
+	`
+	row := repository.Data.DB.QueryRowContext(ctx, query, tokenHash)
+	var t auth.RefreshToken
+	err := row.Scan(&t.ID, &t.UserID, &t.FamilyID, &t.TokenHash, &t.ExpiresAt, &t.RevokedAt, &t.ReplacedBy, &t.CreatedAt)
+	if err != nil {
+		return auth.RefreshToken{}, translateError(err)
+	}
+	return t, nil
+}
+
+// Rotate stores newToken and revokes the old one in a single transaction. If the old token was revoked
+// concurrently it returns auth.ErrRefreshTokenRevoked and nothing is stored.
+func (repository *RefreshTokenRepository) Rotate(ctx context.Context, oldTokenId uint, newToken *auth.RefreshToken) error {
+	tx, err := repository.Data.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	insert := `
+	INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id;
+	`
+	row := tx.QueryRowContext(ctx, insert,
+		newToken.UserID, newToken.FamilyID, newToken.TokenHash, newToken.ExpiresAt, newToken.CreatedAt,
+	)
+	if err := row.Scan(&newToken.ID); err != nil {
+		return err
+	}
+
+	revoke := `
+	UPDATE refresh_tokens SET revoked_at=$1, replaced_by=$2
+	WHERE id=$3 AND revoked_at IS NULL;
+	`
+	result, err := tx.ExecContext(ctx, revoke, time.Now(), newToken.ID, oldTokenId)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return auth.ErrRefreshTokenRevoked
+	}
+	return tx.Commit()
+}
+
+func (repository *RefreshTokenRepository) RevokeFamily(ctx context.Context, familyId string) error {
+	revoke := `
+	UPDATE refresh_tokens SET revoked_at=$1
+	WHERE family_id=$2 AND revoked_at IS NULL;
+	`
+	_, err := repository.Data.DB.ExecContext(ctx, revoke, time.Now(), familyId)
+	return err
+}
@@ -0,0 +1,63 @@
+package data
+
+import (
+	"context"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/tag"
+)
+
+type TagRepository struct {
+	Data *Data
+}
+
+func NewTagRepository(connection *Data) *TagRepository {
+	return &TagRepository{
+		Data: connection,
+	}
+}
+
+// GetAll returns every tag along with the number of published posts that have it.
+func (repository *TagRepository) GetAll(ctx context.Context) ([]tag.Tag, error) {
+	query := `
+	SELECT t.id, t.name, t.slug, COUNT(pt.post_id)
+	FROM tags t
+	LEFT JOIN post_tags pt ON pt.tag_id = t.id
+		AND EXISTS (SELECT 1 FROM posts p WHERE p.id = pt.post_id AND p.status = 'published' AND p.deleted_at IS NULL)
+	GROUP BY t.id
+	ORDER BY t.name;
+	`
+	rows, err := repository.Data.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var tags []tag.Tag
+	for rows.Next() {
+		var t tag.Tag
+		if err := rows.Scan(&t.ID, &t.Name, &t.Slug, &t.PostCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+func (repository *TagRepository) GetBySlug(ctx context.Context, slug string) (tag.Tag, error) {
+	query := `
+	SELECT t.id, t.name, t.slug, COUNT(pt.post_id)
+	FROM tags t
+	LEFT JOIN post_tags pt ON pt.tag_id = t.id
+		AND EXISTS (SELECT 1 FROM posts p WHERE p.id = pt.post_id AND p.status = 'published' AND p.deleted_at IS NULL)
+	WHERE t.slug = $1
+	GROUP BY t.id;
+	`
+	row := repository.Data.DB.QueryRowContext(ctx, query, slug)
+	var t tag.Tag
+	err := row.Scan(&t.ID, &t.Name, &t.Slug, &t.PostCount)
+	if err != nil {
+		return tag.Tag{}, translateError(err)
+	}
+	return t, nil
+}
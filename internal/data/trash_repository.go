@@ -0,0 +1,100 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/page"
+	"github.com/cortzero/go-postgres-blog/internal/model/trash"
+)
+
+type TrashRepository struct {
+	Data *Data
+}
+
+func NewTrashRepository(connection *Data) *TrashRepository {
+	return &TrashRepository{
+		Data: connection,
+	}
+}
+
+// GetAll returns a page of the deleted posts and users, the most recently deleted first. The trash can
+// only be paginated by offset.
+func (repository *TrashRepository) GetAll(ctx context.Context, req page.Request) (page.Page[trash.Item], error) {
+	var total int
+	count := `
+	SELECT (SELECT COUNT(*) FROM posts WHERE deleted_at IS NOT NULL) + (SELECT COUNT(*) FROM users WHERE deleted_at IS NOT NULL);
+	`
+	if err := repository.Data.DB.QueryRowContext(ctx, count).Scan(&total); err != nil {
+		return page.Page[trash.Item]{}, err
+	}
+
+	query := `
+	SELECT 'post', id, title, deleted_at FROM posts WHERE deleted_at IS NOT NULL
+	UNION ALL
+	SELECT 'user', id, username, deleted_at FROM users WHERE deleted_at IS NOT NULL
+	ORDER BY 4 DESC, 2 DESC
+	LIMIT $1 OFFSET $2;
+	`
+	rows, err := repository.Data.DB.QueryContext(ctx, query, req.Limit+1, req.Offset)
+	if err != nil {
+		return page.Page[trash.Item]{}, err
+	}
+
+	defer rows.Close()
+
+	var items []trash.Item
+	for rows.Next() {
+		var item trash.Item
+		if err := rows.Scan(&item.Type, &item.ID, &item.Name, &item.DeletedAt); err != nil {
+			return page.Page[trash.Item]{}, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return page.Page[trash.Item]{}, err
+	}
+	return page.New(req, items, total, func(item trash.Item) page.Cursor {
+		return page.Cursor{CreatedAt: item.DeletedAt, ID: item.ID}
+	}), nil
+}
+
+// Purge permanently removes the posts and users deleted before the time in a single transaction. The
+// comments of the purged users stay in their threads as deleted comments without an author.
+func (repository *TrashRepository) Purge(ctx context.Context, deletedBefore time.Time) (trash.Purged, error) {
+	// Users are only purged once they have no posts left, which are purged along with them
+	purgeable := `SELECT id FROM users WHERE deleted_at < $1 AND NOT EXISTS (SELECT 1 FROM posts WHERE user_id = users.id)`
+
+	tx, err := repository.Data.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return trash.Purged{}, err
+	}
+
+	defer tx.Rollback()
+
+	var purged trash.Purged
+	result, err := tx.ExecContext(ctx, `DELETE FROM posts WHERE deleted_at < $1;`, deletedBefore)
+	if err != nil {
+		return trash.Purged{}, err
+	}
+	if purged.Posts, err = result.RowsAffected(); err != nil {
+		return trash.Purged{}, err
+	}
+
+	eraseComments := `UPDATE comments SET body='', deleted_at=COALESCE(deleted_at, $2) WHERE user_id IN (` + purgeable + `);`
+	if _, err := tx.ExecContext(ctx, eraseComments, deletedBefore, time.Now()); err != nil {
+		return trash.Purged{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE user_id IN (`+purgeable+`);`, deletedBefore); err != nil {
+		return trash.Purged{}, err
+	}
+
+	result, err = tx.ExecContext(ctx, `DELETE FROM users WHERE id IN (`+purgeable+`);`, deletedBefore)
+	if err != nil {
+		return trash.Purged{}, err
+	}
+	if purged.Users, err = result.RowsAffected(); err != nil {
+		return trash.Purged{}, err
+	}
+	return purged, tx.Commit()
+}
@@ -2,11 +2,21 @@ package data
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"time"
 
+	"github.com/cortzero/go-postgres-blog/internal/model/page"
 	"github.com/cortzero/go-postgres-blog/internal/model/user"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
 )
 
+// userConstraintErrors maps the unique indexes of the users to the errors of the model
+var userConstraintErrors = map[string]error{
+	"uq_users_username_lower": user.ErrUsernameTaken,
+	"uq_users_email_lower":    user.ErrEmailTaken,
+}
+
 type UserRepositoy struct {
 	Data *Data
 }
@@ -17,79 +27,94 @@ func NewUserRepository(connection *Data) *UserRepositoy {
 	}
 }
 
-func (repository *UserRepositoy) GetAll(ctx context.Context) ([]user.User, error) {
-	query := `
-	SELECT id, first_name, last_name, username, email, picture, created_at, updated_at
-	FROM users;
-	`
-	rows, err := repository.Data.DB.QueryContext(ctx, query)
+func (repository *UserRepositoy) GetAll(ctx context.Context, req page.Request) (page.Page[user.User], error) {
+	var total int
+	count := `SELECT COUNT(*) FROM users WHERE deleted_at IS NULL;`
+	if err := repository.Data.DB.QueryRowContext(ctx, count).Scan(&total); err != nil {
+		return page.Page[user.User]{}, err
+	}
+
+	conditions, orderAndLimit, args := paginate(req, "", []string{"deleted_at IS NULL"}, nil)
+	query := fmt.Sprintf(`
+	SELECT id, first_name, last_name, username, email, picture, role, version, created_at, COALESCE(updated_at, '0001-01-01T00:00:00Z')
+	FROM users
+	%s
+	%s;
+	`, where(conditions), orderAndLimit)
+	rows, err := repository.Data.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return page.Page[user.User]{}, err
 	}
 	defer rows.Close()
 	var users []user.User
 	for rows.Next() {
-		var user user.User
-		rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Username, &user.Email,
-			&user.Picture, &user.CreatedAt, &user.UpdatedAt)
-		users = append(users, user)
+		var u user.User
+		err := rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Username, &u.Email,
+			&u.Picture, &u.Role, &u.Version, &u.CreatedAt, &u.UpdatedAt)
+		if err != nil {
+			return page.Page[user.User]{}, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return page.Page[user.User]{}, err
 	}
-	return users, nil
+	return page.New(req, users, total, userCursor), nil
 }
 
 func (repository *UserRepositoy) GetById(ctx context.Context, id uint) (user.User, error) {
 	query := `
-	SELECT id, first_name, last_name, username, email, picture, created_at, updated_at
+	SELECT id, first_name, last_name, username, email, picture, role, version, created_at, updated_at
 	FROM users
-	WHERE id = $1;
+	WHERE id = $1 AND deleted_at IS NULL;
 	`
 	row := repository.Data.DB.QueryRowContext(ctx, query, id)
 	var u user.User
 	err := row.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Username, &u.Email,
-		&u.Picture, &u.CreatedAt, &u.UpdatedAt)
+		&u.Picture, &u.Role, &u.Version, &u.CreatedAt, &u.UpdatedAt)
 	if err != nil {
-		return user.User{}, err
+		return user.User{}, translateError(err)
 	}
 	return u, nil
 }
 
 func (repository *UserRepositoy) GetByUsername(ctx context.Context, username string) (user.User, error) {
 	query := `
-	SELECT id, first_name, last_name, username, password, email, picture, created_at, updated_at
+	SELECT id, first_name, last_name, username, password, email, picture, role, version, created_at, updated_at
 	FROM users
-	WHERE username = $1;
+	WHERE lower(username) = lower($1) AND deleted_at IS NULL;
 	`
 	row := repository.Data.DB.QueryRowContext(ctx, query, username)
 	var u user.User
 	err := row.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Username, &u.PasswordHash, &u.Email,
-		&u.Picture, &u.CreatedAt, &u.UpdatedAt)
+		&u.Picture, &u.Role, &u.Version, &u.CreatedAt, &u.UpdatedAt)
 	if err != nil {
-		return user.User{}, err
+		return user.User{}, translateError(err)
 	}
 	return u, nil
 }
 
 func (repository *UserRepositoy) GetByEmail(ctx context.Context, email string) (user.User, error) {
 	query := `
-	SELECT id, first_name, last_name, username, password, email, picture, created_at, updated_at
+	SELECT id, first_name, last_name, username, password, email, picture, role, version, created_at, updated_at
 	FROM users
-	WHERE email = $1;
+	WHERE lower(email) = lower($1) AND deleted_at IS NULL;
 	`
 	row := repository.Data.DB.QueryRowContext(ctx, query, email)
 	var u user.User
 	err := row.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Username, &u.PasswordHash, &u.Email,
-		&u.Picture, &u.CreatedAt, &u.UpdatedAt)
+		&u.Picture, &u.Role, &u.Version, &u.CreatedAt, &u.UpdatedAt)
 	if err != nil {
-		return user.User{}, err
+		return user.User{}, translateError(err)
 	}
 	return u, nil
 }
 
 func (repository *UserRepositoy) Create(ctx context.Context, user *user.User) error {
 	insert := `
-	INSERT INTO users (first_name, last_name, username, password, email, picture, created_at, updated_at)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	RETURNING id;
+	INSERT INTO users (first_name, last_name, username, password, email, picture, role, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	RETURNING id, version;
 	`
 	// Sets default photo
 	// if user.Picture == "" {
@@ -102,20 +127,21 @@ func (repository *UserRepositoy) Create(ctx context.Context, user *user.User) er
 	// }
 
 	row := repository.Data.DB.QueryRowContext(ctx, insert,
-		user.FirstName, user.LastName, user.Username, user.PasswordHash, user.Email, user.Picture, user.CreatedAt, user.UpdatedAt,
+		user.FirstName, user.LastName, user.Username, user.PasswordHash, user.Email, user.Picture, user.Role, user.CreatedAt, user.UpdatedAt,
 	)
 
-	err := row.Scan(&user.ID)
+	err := row.Scan(&user.ID, &user.Version)
 	if err != nil {
-		return err
+		return translateUserError(err)
 	}
 	return nil
 }
 
+// Update changes the profile of the user while it is at user.Version, which is then incremented.
 func (repository *UserRepositoy) Update(ctx context.Context, id uint, user user.User) error {
 	update := `
-	UPDATE users SET first_name=$1, last_name=$2, email=$3, picture=$4, updated_at=$5
-	WHERE id=$6;
+	UPDATE users SET first_name=$1, last_name=$2, email=$3, picture=$4, updated_at=$5, version=version+1
+	WHERE id=$6 AND version=$7 AND deleted_at IS NULL;
 	`
 	stmt, err := repository.Data.DB.PrepareContext(ctx, update)
 	if err != nil {
@@ -124,34 +150,139 @@ func (repository *UserRepositoy) Update(ctx context.Context, id uint, user user.
 
 	defer stmt.Close()
 
-	result, err := stmt.ExecContext(ctx, user.FirstName, user.LastName, user.Email, user.Picture, user.UpdatedAt, id)
+	result, err := stmt.ExecContext(ctx, user.FirstName, user.LastName, user.Email, user.Picture, user.UpdatedAt, id, user.Version)
 	if err != nil {
-		return err
+		return translateUserError(err)
 	}
 	rows, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
 	if rows == 0 {
-		err = fmt.Errorf("the user with id '%d' does not exist", id)
+		return notChanged(ctx, repository.Data.DB, "users", "user", id, user.Version)
+	}
+	return nil
+}
+
+// UpdateRole changes the role of the user while it is at the version, which is then incremented.
+func (repository *UserRepositoy) UpdateRole(ctx context.Context, id uint, version int, role user.Role) error {
+	update := `
+	UPDATE users SET role=$1, updated_at=$2, version=version+1
+	WHERE id=$3 AND version=$4 AND deleted_at IS NULL;
+	`
+	result, err := repository.Data.DB.ExecContext(ctx, update, role, time.Now(), id, version)
+	if err != nil {
 		return err
 	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return notChanged(ctx, repository.Data.DB, "users", "user", id, version)
+	}
 	return nil
 }
 
-func (repository *UserRepositoy) Delete(ctx context.Context, id uint) error {
+// Delete moves the user to the trash while it is at the version and revokes their sessions, in a single
+// transaction along with what the policy of the deletion does to their posts. A dry run reports what would
+// be affected and rolls the transaction back.
+func (repository *UserRepositoy) Delete(ctx context.Context, id uint, version int, deletion user.Deletion) (user.DeletionReport, error) {
 	delete := `
-	DELETE FROM users WHERE id=$1;
+	UPDATE users SET deleted_at=$3, version=version+1
+	WHERE id=$1 AND version=$2 AND deleted_at IS NULL;
 	`
+	tx, err := repository.Data.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return user.DeletionReport{}, err
+	}
+
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.ExecContext(ctx, delete, id, version, now)
+	if err != nil {
+		return user.DeletionReport{}, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return user.DeletionReport{}, err
+	}
+	if rows == 0 {
+		return user.DeletionReport{}, notChanged(ctx, tx, "users", "user", id, version)
+	}
+
+	report := user.DeletionReport{UserID: id, Policy: deletion.Policy, DryRun: deletion.DryRun}
+	switch deletion.Policy {
+	case user.DeletionReassign, user.DeletionAnonymize:
+		if deletion.Policy == user.DeletionAnonymize {
+			ghost := `SELECT id FROM users WHERE username = $1;`
+			if err := tx.QueryRowContext(ctx, ghost, user.GhostUsername).Scan(&report.ReassignTo); err != nil {
+				return user.DeletionReport{}, fmt.Errorf("the ghost account '%s' does not exist: %w", user.GhostUsername, err)
+			}
+		} else {
+			// The receiver is locked so that it can't be deleted before the posts are given to it
+			receiver := `SELECT id FROM users WHERE id = $1 AND deleted_at IS NULL FOR SHARE;`
+			if err := tx.QueryRowContext(ctx, receiver, deletion.ReassignTo).Scan(&report.ReassignTo); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return user.DeletionReport{}, notFound("user", deletion.ReassignTo)
+				}
+				return user.DeletionReport{}, err
+			}
+		}
+
+		// The posts in the trash are given too, so that nothing keeps the user from being purged
+		reassign := `UPDATE posts SET user_id=$2, version=version+1 WHERE user_id=$1;`
+		if report.Posts, err = execCount(ctx, tx, reassign, id, report.ReassignTo); err != nil {
+			return user.DeletionReport{}, err
+		}
+		if deletion.Policy == user.DeletionAnonymize {
+			anonymize := `UPDATE comments SET user_id=$2 WHERE user_id=$1;`
+			if report.Comments, err = execCount(ctx, tx, anonymize, id, report.ReassignTo); err != nil {
+				return user.DeletionReport{}, err
+			}
+		}
+	default:
+		// The posts get the same deletion time so that they are restored along with the user
+		deletePosts := `UPDATE posts SET deleted_at=$2, version=version+1 WHERE user_id=$1 AND deleted_at IS NULL;`
+		if report.Posts, err = execCount(ctx, tx, deletePosts, id, now); err != nil {
+			return user.DeletionReport{}, err
+		}
+	}
 
-	stmt, err := repository.Data.DB.PrepareContext(ctx, delete)
+	// A deleted user can't keep refreshing their access tokens
+	revoke := `UPDATE refresh_tokens SET revoked_at=$2 WHERE user_id=$1 AND revoked_at IS NULL;`
+	if report.Sessions, err = execCount(ctx, tx, revoke, id, now); err != nil {
+		return user.DeletionReport{}, err
+	}
+
+	if deletion.DryRun {
+		return report, nil
+	}
+	return report, tx.Commit()
+}
+
+// Restore takes the user out of the trash along with the posts that were deleted with them.
+func (repository *UserRepositoy) Restore(ctx context.Context, id uint) error {
+	restorePosts := `
+	UPDATE posts SET deleted_at=NULL, version=version+1
+	WHERE user_id=$1 AND deleted_at = (SELECT deleted_at FROM users WHERE id=$1);
+	`
+	restore := `
+	UPDATE users SET deleted_at=NULL, version=version+1
+	WHERE id=$1 AND deleted_at IS NOT NULL;
+	`
+	tx, err := repository.Data.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	defer stmt.Close()
+	defer tx.Rollback()
 
-	result, err := stmt.ExecContext(ctx, id)
+	if _, err := tx.ExecContext(ctx, restorePosts, id); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, restore, id)
 	if err != nil {
 		return err
 	}
@@ -160,8 +291,33 @@ func (repository *UserRepositoy) Delete(ctx context.Context, id uint) error {
 		return err
 	}
 	if rows == 0 {
-		err = fmt.Errorf("the user with id '%d' does not exist", id)
-		return err
+		return notFound("deleted user", id)
 	}
-	return nil
+	return tx.Commit()
+}
+
+func userCursor(u user.User) page.Cursor {
+	return page.Cursor{CreatedAt: u.CreatedAt, ID: u.ID}
+}
+
+// translateUserError translates the errors of the driver, telling which of the username or the email
+// is taken when a change violates their unique indexes.
+func translateUserError(err error) error {
+	err = translateError(err)
+	var conflict *errors.ConflictError
+	if errors.As(err, &conflict) {
+		if modelErr, ok := userConstraintErrors[conflict.Constraint]; ok {
+			return fmt.Errorf("%w: %w", modelErr, conflict)
+		}
+	}
+	return err
+}
+
+// execCount runs the statement in the transaction and returns the number of rows it affected.
+func execCount(ctx context.Context, tx *sql.Tx, statement string, args ...any) (int64, error) {
+	result, err := tx.ExecContext(ctx, statement, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
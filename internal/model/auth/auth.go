@@ -0,0 +1,18 @@
+package auth
+
+import "time"
+
+// Credentials holds the data sent by a user to log in. Login accepts either the username or the email.
+type Credentials struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+// Token is returned to the user after a successful login or refresh.
+type Token struct {
+	AccessToken      string    `json:"access_token"`
+	TokenType        string    `json:"token_type"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/user"
+)
+
+// Principal is the authenticated user that performs a request.
+type Principal struct {
+	UserID uint
+	Role   user.Role
+}
+
+type contextKey struct{}
+
+var principalKey = contextKey{}
+
+// WithPrincipal returns a copy of ctx that carries the authenticated user.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// PrincipalFromContext returns the authenticated user, if there is one.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey).(Principal)
+	return principal, ok
+}
+
+// UserIDFromContext returns the id of the authenticated user, if there is one.
+func UserIDFromContext(ctx context.Context) (uint, bool) {
+	principal, ok := PrincipalFromContext(ctx)
+	return principal.UserID, ok
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrRefreshTokenRevoked is returned by the repository when a refresh token was already revoked or rotated.
+var ErrRefreshTokenRevoked = errors.New("the refresh token was already revoked")
+
+// RefreshToken is a long-lived session credential. Only the hash of the token is stored.
+// All the tokens obtained by rotating the one issued at login share the same FamilyID.
+type RefreshToken struct {
+	ID         uint
+	UserID     uint
+	FamilyID   string
+	TokenHash  string
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+	ReplacedBy *uint
+	CreatedAt  time.Time
+}
+
+// RefreshRequest is the body sent to refresh a session or to log out.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (t RefreshToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
+
+func (t RefreshToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// NewOpaqueToken returns a random URL-safe token along with its hash.
+func NewOpaqueToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex encoded SHA-256 of the token, which is what gets stored in the database.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
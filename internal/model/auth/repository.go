@@ -0,0 +1,11 @@
+package auth
+
+import "context"
+
+// RefreshTokenRepository handles the storage of refresh tokens
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *RefreshToken) error
+	GetByHash(ctx context.Context, tokenHash string) (RefreshToken, error)
+	Rotate(ctx context.Context, oldTokenId uint, newToken *RefreshToken) error
+	RevokeFamily(ctx context.Context, familyId string) error
+}
@@ -0,0 +1,15 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+)
+
+// auth.Service is the interface that a service layer component must fullfil to authenticate users.
+type Service interface {
+	Login(ctx context.Context, credentials Credentials) (Token, *errors.CustomError)
+	Refresh(ctx context.Context, refreshToken string) (Token, *errors.CustomError)
+	Logout(ctx context.Context, refreshToken string) *errors.CustomError
+	Authenticate(ctx context.Context, accessToken string) (Principal, *errors.CustomError)
+}
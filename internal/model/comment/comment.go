@@ -0,0 +1,26 @@
+package comment
+
+import "time"
+
+const (
+	// MaxDepth is the deepest level of nesting a reply can have. Top-level comments have depth 0.
+	MaxDepth = 8
+	// MaxBodyLength is the maximum number of characters of a comment.
+	MaxBodyLength = 5000
+)
+
+// Comment is a reply to a post or, when ParentID is set, to another comment of the same post.
+// Deleted comments keep their place in the thread but their body is not returned.
+type Comment struct {
+	ID        uint       `json:"id,omitempty"`
+	PostID    uint       `json:"post_id,omitempty"`
+	UserID    uint       `json:"user_id,omitempty"`
+	ParentID  *uint      `json:"parent_id,omitempty"`
+	Depth     int        `json:"depth"`
+	Body      string     `json:"body"`
+	Deleted   bool       `json:"deleted"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"-"`
+	Replies   []*Comment `json:"replies,omitempty"`
+}
@@ -0,0 +1,12 @@
+package comment
+
+import "context"
+
+// Repository handles the CRUD operations for Comment
+type Repository interface {
+	GetByPost(ctx context.Context, postId uint, maxDepth int) ([]Comment, error)
+	GetById(ctx context.Context, id uint) (Comment, error)
+	Create(ctx context.Context, comment *Comment) error
+	Update(ctx context.Context, id uint, comment Comment) error
+	SoftDelete(ctx context.Context, id uint) error
+}
@@ -0,0 +1,15 @@
+package comment
+
+import (
+	"context"
+
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+)
+
+// comment.Service is the interface that a service layer component must fullfil to manage the comments of posts.
+type Service interface {
+	CreateComment(ctx context.Context, postId uint, comment *Comment) *errors.CustomError
+	UpdateComment(ctx context.Context, postId uint, id uint, comment *Comment) *errors.CustomError
+	DeleteComment(ctx context.Context, postId uint, id uint) *errors.CustomError
+	GetCommentTree(ctx context.Context, postId uint, maxDepth int) ([]*Comment, *errors.CustomError)
+}
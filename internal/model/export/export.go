@@ -0,0 +1,77 @@
+package export
+
+import (
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/comment"
+	"github.com/cortzero/go-postgres-blog/internal/model/post"
+	"github.com/cortzero/go-postgres-blog/internal/model/user"
+)
+
+const (
+	// MaxSyncItems is the largest number of posts, comments and revisions of an account that is exported
+	// while the request waits. Larger accounts are exported in the background.
+	MaxSyncItems = 500
+	// DefaultExpiry is how long an archive produced in the background can be downloaded
+	DefaultExpiry = 7 * 24 * time.Hour
+	// ClaimTimeout is how long a pending export claimed by an instance is left to it. After that another
+	// instance can claim it, in case the first one stopped before producing it.
+	ClaimTimeout = 30 * time.Minute
+)
+
+// Status is the state of an export produced in the background
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusReady   Status = "ready"
+	StatusFailed  Status = "failed"
+)
+
+// Export is a request for the archive of the personal data of a user, produced in the background.
+type Export struct {
+	ID          uint       `json:"id"`
+	UserID      uint       `json:"user_id"`
+	Status      Status     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	Size        int        `json:"size,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// Session is a login of the user, which lasts as long as its refresh tokens. The tokens are not exported.
+type Session struct {
+	ID        uint       `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// AuditEntry is a change made by the user. The revisions of the posts are the only changes recorded
+// along with who made them.
+type AuditEntry struct {
+	Action    string    `json:"action"`
+	PostID    uint      `json:"post_id"`
+	Revision  int       `json:"revision"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Post is a post of the user along with the time it was moved to the trash, if it was.
+type Post struct {
+	post.Post
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ActionPostRevision is the action of the audit entries of the revisions of posts
+const ActionPostRevision = "post_revision"
+
+// Contents is everything stored about a user. The posts and comments include the deleted ones.
+type Contents struct {
+	User     user.User         `json:"user"`
+	Posts    []Post            `json:"posts"`
+	Comments []comment.Comment `json:"comments"`
+	Sessions []Session         `json:"sessions"`
+	Audit    []AuditEntry      `json:"audit"`
+}
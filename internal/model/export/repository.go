@@ -0,0 +1,19 @@
+package export
+
+import (
+	"context"
+	"time"
+)
+
+// Repository gathers the personal data of users and stores the exports produced in the background.
+type Repository interface {
+	Count(ctx context.Context, userId uint) (int, error)
+	Collect(ctx context.Context, userId uint) (Contents, error)
+	Create(ctx context.Context, userId uint) (Export, error)
+	GetById(ctx context.Context, userId uint, id uint) (Export, error)
+	GetArchive(ctx context.Context, userId uint, id uint) ([]byte, error)
+	ClaimPending(ctx context.Context, limit int) ([]Export, error)
+	Complete(ctx context.Context, id uint, archive []byte, expiresAt time.Time) error
+	Fail(ctx context.Context, id uint, reason string, expiresAt time.Time) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
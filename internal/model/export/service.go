@@ -0,0 +1,15 @@
+package export
+
+import (
+	"context"
+
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+)
+
+// export.Service is the interface that a service layer component must fullfil to export the personal data of users.
+type Service interface {
+	ExportUser(ctx context.Context, userId uint) ([]byte, Export, *errors.CustomError)
+	GetExport(ctx context.Context, userId uint, id uint) (Export, *errors.CustomError)
+	GetArchive(ctx context.Context, userId uint, id uint) ([]byte, *errors.CustomError)
+	ProcessExports(ctx context.Context) (int, *errors.CustomError)
+}
@@ -0,0 +1,113 @@
+package page
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("the cursor is not valid")
+
+// Cursor points to a row of a listing sorted by (created_at, id). It is sent to clients as an opaque string.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uint      `json:"i"`
+	// Backward is true when the cursor asks for the rows that come before it
+	Backward bool `json:"b,omitempty"`
+}
+
+// Encode returns the opaque representation of the cursor.
+func (c Cursor) Encode() string {
+	j, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(j)
+}
+
+// DecodeCursor parses a cursor previously returned by Encode.
+func DecodeCursor(encoded string) (Cursor, error) {
+	j, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(j, &c); err != nil || c.ID == 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Request describes the page of a listing that is requested. When Cursor is set, Offset is ignored.
+type Request struct {
+	Limit  int
+	Offset int
+	Cursor *Cursor
+}
+
+// Normalized returns a copy of the request with the limit and the offset within bounds.
+func (r Request) Normalized() Request {
+	if r.Limit <= 0 {
+		r.Limit = DefaultLimit
+	}
+	if r.Limit > MaxLimit {
+		r.Limit = MaxLimit
+	}
+	if r.Offset < 0 || r.Cursor != nil {
+		r.Offset = 0
+	}
+	return r
+}
+
+// Page is a slice of a listing along with what is needed to request the surrounding pages.
+type Page[T any] struct {
+	Items      []T
+	Total      int
+	HasNext    bool
+	HasPrev    bool
+	NextCursor *Cursor
+	PrevCursor *Cursor
+}
+
+// New builds a page from the rows fetched for the request. The rows must have been fetched with one
+// more element than the limit, in the order of the request direction, so that it can be detected
+// whether there are more rows after them.
+func New[T any](req Request, rows []T, total int, keyOf func(T) Cursor) Page[T] {
+	more := len(rows) > req.Limit
+	if more {
+		rows = rows[:req.Limit]
+	}
+	if rows == nil {
+		rows = []T{}
+	}
+
+	p := Page[T]{Total: total}
+	switch {
+	case req.Cursor != nil && req.Cursor.Backward:
+		// Backward rows come in reverse order
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+		p.HasPrev = more
+		p.HasNext = true
+	case req.Cursor != nil:
+		p.HasNext = more
+		p.HasPrev = true
+	default:
+		p.HasNext = more
+		p.HasPrev = req.Offset > 0
+	}
+
+	p.Items = rows
+	if len(rows) > 0 {
+		next := keyOf(rows[len(rows)-1])
+		prev := keyOf(rows[0])
+		prev.Backward = true
+		p.NextCursor = &next
+		p.PrevCursor = &prev
+	}
+	return p
+}
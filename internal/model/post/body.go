@@ -0,0 +1,23 @@
+package post
+
+// BodyFormat is the markup language of the body of a post
+type BodyFormat string
+
+const (
+	FormatMarkdown BodyFormat = "markdown"
+	FormatPlain    BodyFormat = "plain"
+	FormatHTML     BodyFormat = "html"
+)
+
+func (f BodyFormat) IsValid() bool {
+	return f == FormatMarkdown || f == FormatPlain || f == FormatHTML
+}
+
+// Heading is an entry of the table of contents of a post, linking to the anchor of a heading of the body.
+// Lower level headings that follow it are nested as its children.
+type Heading struct {
+	Level    int       `json:"level"`
+	Text     string    `json:"text"`
+	Anchor   string    `json:"anchor"`
+	Children []Heading `json:"children,omitempty"`
+}
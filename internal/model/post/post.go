@@ -1,12 +1,56 @@
 package post
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+// MaxTitleLength is the maximum number of characters of the title of a post.
+const MaxTitleLength = 150
+
+// ErrAuthorDeleted is returned by the repository when a post is restored while its author is in the trash.
+var ErrAuthorDeleted = errors.New("the author of the post is deleted")
 
 type Post struct {
-	ID        uint      `json:"id,omitempty"`
-	UserID    uint      `json:"user_id,omitempty"`
-	Title     string    `json:"title,omitempty"`
-	Body      string    `json:"body,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID     uint   `json:"id,omitempty"`
+	UserID uint   `json:"user_id,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Slug   string `json:"slug,omitempty"`
+	Body   string `json:"body,omitempty"`
+	// BodyFormat is the markup of Body, which is rendered to the sanitized BodyHTML when the post is saved
+	BodyFormat  BodyFormat `json:"body_format,omitempty"`
+	BodyHTML    string     `json:"body_html,omitempty"`
+	TOC         []Heading  `json:"toc,omitempty"`
+	Tags        []string   `json:"tags"`
+	Status      Status     `json:"status,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	Version     int        `json:"version,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Content is the part of a post that is changed by an update. PATCH requests are applied to it.
+type Content struct {
+	Title      string     `json:"title"`
+	Body       string     `json:"body"`
+	BodyFormat BodyFormat `json:"body_format"`
+	Tags       []string   `json:"tags"`
+}
+
+// Content returns the fields of the post that are changed by an update.
+func (p Post) Content() Content {
+	tags := p.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return Content{Title: p.Title, Body: p.Body, BodyFormat: p.BodyFormat, Tags: tags}
+}
+
+// Post returns a post with the fields of the content. Missing tags remove the tags of the post.
+func (c Content) Post() Post {
+	tags := c.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return Post{Title: c.Title, Body: c.Body, BodyFormat: c.BodyFormat, Tags: tags}
 }
@@ -0,0 +1,40 @@
+package post
+
+import (
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/page"
+)
+
+// SortableFields are the fields that listings of posts can be sorted by
+var SortableFields = []string{"id", "title", "created_at", "updated_at"}
+
+// SortField sorts a listing by a field, in descending order when Desc is true
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// Query describes which posts to list and in which order. Zero values mean no filter, except
+// for Status which the service defaults to published.
+// Cursor pagination is only available with the default sort (newest first).
+type Query struct {
+	AuthorID       *uint
+	AuthorUsername string
+	TagSlug        string
+	Status         Status
+	CreatedAfter   *time.Time
+	CreatedBefore  *time.Time
+	TitleContains  string
+	Sort           []SortField
+	Page           page.Request
+}
+
+func IsSortable(field string) bool {
+	for _, f := range SortableFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
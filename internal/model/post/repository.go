@@ -1,13 +1,26 @@
 package post
 
-import "context"
+import (
+	"context"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/page"
+)
 
 // Repository handles the CRUD operations for Post
 type Repository interface {
-	GetAll(ctx context.Context) ([]Post, error)
+	GetAll(ctx context.Context, query Query) (page.Page[Post], error)
 	GetById(ctx context.Context, id uint) (Post, error)
-	GetByUser(ctx context.Context, userId uint) ([]Post, error)
+	GetBySlug(ctx context.Context, slug string) (Post, error)
+	GetByFormerSlug(ctx context.Context, slug string) (Post, error)
+	Search(ctx context.Context, terms string, req page.Request) (page.Page[SearchResult], error)
+	GetByUser(ctx context.Context, userId uint, status Status, req page.Request) (page.Page[Post], error)
 	Create(ctx context.Context, post *Post) error
-	Update(ctx context.Context, id uint, post Post) error
-	Delete(ctx context.Context, id uint) error
+	Update(ctx context.Context, id uint, post Post, editorId uint) error
+	UpdateStatus(ctx context.Context, id uint, version int, status Status, publishedAt *time.Time) error
+	PublishDue(ctx context.Context, now time.Time) (int64, error)
+	Delete(ctx context.Context, id uint, version int) error
+	Restore(ctx context.Context, id uint) error
+	GetRevisions(ctx context.Context, postId uint) ([]Revision, error)
+	GetRevision(ctx context.Context, postId uint, number int) (Revision, error)
 }
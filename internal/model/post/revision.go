@@ -0,0 +1,30 @@
+package post
+
+import "time"
+
+// Revision is a snapshot of the title, the body and the format of the body of a post. A revision is
+// appended every time the post is created, updated or restored, numbered from 1 for each post.
+type Revision struct {
+	ID         uint       `json:"id"`
+	PostID     uint       `json:"post_id"`
+	Number     int        `json:"revision"`
+	EditorID   *uint      `json:"editor_id"`
+	Title      string     `json:"title"`
+	Body       string     `json:"body"`
+	BodyFormat BodyFormat `json:"body_format"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// RevisionDiff is the line-level difference between two revisions of a post
+type RevisionDiff struct {
+	From  int        `json:"from"`
+	To    int        `json:"to"`
+	Title []DiffLine `json:"title"`
+	Body  []DiffLine `json:"body"`
+}
+
+// DiffLine is a line kept ('equal'), inserted ('insert') or deleted ('delete') between two revisions
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
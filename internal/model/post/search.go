@@ -0,0 +1,10 @@
+package post
+
+// SearchResult is a post matching a full-text search. The highlighted fields are HTML-escaped
+// text where the matching terms are wrapped in <mark> tags.
+type SearchResult struct {
+	Post             Post    `json:"post"`
+	Rank             float64 `json:"rank"`
+	HighlightedTitle string  `json:"highlighted_title"`
+	Snippet          string  `json:"snippet"`
+}
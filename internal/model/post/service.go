@@ -3,14 +3,22 @@ package post
 import (
 	"context"
 
+	"github.com/cortzero/go-postgres-blog/internal/model/page"
 	"github.com/cortzero/go-postgres-blog/internal/service/errors"
 )
 
 type Service interface {
 	CreatePost(ctx context.Context, post *Post) *errors.CustomError
-	UpdatePost(ctx context.Context, id uint, post *Post) *errors.CustomError
-	DeletePost(ctx context.Context, id uint) *errors.CustomError
-	GetAllPosts(ctx context.Context) ([]Post, *errors.CustomError)
+	UpdatePost(ctx context.Context, id uint, post *Post) (Post, *errors.CustomError)
+	DeletePost(ctx context.Context, id uint, version int) *errors.CustomError
+	RestorePost(ctx context.Context, id uint) (Post, *errors.CustomError)
+	ChangePostStatus(ctx context.Context, id uint, version int, change StatusChange) (Post, *errors.CustomError)
+	GetAllPosts(ctx context.Context, query Query) (page.Page[Post], *errors.CustomError)
+	Search(ctx context.Context, terms string, req page.Request) (page.Page[SearchResult], *errors.CustomError)
 	GetPostById(ctx context.Context, id uint) (Post, *errors.CustomError)
-	GetPostsByUserId(ctx context.Context, userId uint) ([]Post, *errors.CustomError)
+	GetPostBySlug(ctx context.Context, slug string) (Post, *errors.CustomError)
+	GetPostRevisions(ctx context.Context, id uint) ([]Revision, *errors.CustomError)
+	DiffPostRevisions(ctx context.Context, id uint, from int, to int) (RevisionDiff, *errors.CustomError)
+	RestorePostRevision(ctx context.Context, id uint, number int) (Post, *errors.CustomError)
+	GetPostsByUserId(ctx context.Context, userId uint, req page.Request) (page.Page[Post], *errors.CustomError)
 }
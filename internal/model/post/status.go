@@ -0,0 +1,43 @@
+package post
+
+import "time"
+
+// Status is the stage of the lifecycle of a post. Only published posts are public.
+type Status string
+
+const (
+	StatusDraft     Status = "draft"
+	StatusScheduled Status = "scheduled"
+	StatusPublished Status = "published"
+	StatusArchived  Status = "archived"
+)
+
+// transitions lists the statuses a post can move to from each status
+var transitions = map[Status][]Status{
+	StatusDraft:     {StatusScheduled, StatusPublished},
+	StatusScheduled: {StatusDraft, StatusPublished},
+	StatusPublished: {StatusDraft, StatusArchived},
+	StatusArchived:  {StatusDraft, StatusPublished},
+}
+
+// StatusChange is the body sent to move a post through its lifecycle. PublishAt is required
+// to schedule a post and ignored otherwise.
+type StatusChange struct {
+	Status    Status     `json:"status"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+}
+
+func (s Status) IsValid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether a post with this status can move to the next one.
+func (s Status) CanTransitionTo(next Status) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,9 @@
+package tag
+
+import "context"
+
+// Repository handles the read operations for Tag. Tags are created and attached by the post repository.
+type Repository interface {
+	GetAll(ctx context.Context) ([]Tag, error)
+	GetBySlug(ctx context.Context, slug string) (Tag, error)
+}
@@ -0,0 +1,13 @@
+package tag
+
+import (
+	"context"
+
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+)
+
+// tag.Service is the interface that a service layer component must fullfil to list tags.
+type Service interface {
+	GetAllTags(ctx context.Context) ([]Tag, *errors.CustomError)
+	GetTagBySlug(ctx context.Context, slug string) (Tag, *errors.CustomError)
+}
@@ -0,0 +1,18 @@
+package tag
+
+const (
+	// MaxPerPost is the maximum number of tags a post can have.
+	MaxPerPost = 10
+	// MaxNameLength is the maximum number of characters of the name of a tag.
+	MaxNameLength = 50
+	// MaxSlugLength is the length of the slug column. Transliterated names can have longer slugs, which are truncated.
+	MaxSlugLength = 60
+)
+
+// Tag groups posts by topic. Posts reference tags by name, and the slug identifies the tag in URLs.
+type Tag struct {
+	ID        uint   `json:"id,omitempty"`
+	Name      string `json:"name"`
+	Slug      string `json:"slug"`
+	PostCount int    `json:"post_count"`
+}
@@ -0,0 +1,14 @@
+package trash
+
+import (
+	"context"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/page"
+)
+
+// Repository lists and purges the trash. Posts and users are moved to the trash and restored by their own repositories.
+type Repository interface {
+	GetAll(ctx context.Context, req page.Request) (page.Page[Item], error)
+	Purge(ctx context.Context, deletedBefore time.Time) (Purged, error)
+}
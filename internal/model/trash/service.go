@@ -0,0 +1,14 @@
+package trash
+
+import (
+	"context"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/page"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+)
+
+// trash.Service is the interface that a service layer component must fullfil to list and purge the trash.
+type Service interface {
+	GetTrash(ctx context.Context, req page.Request) (page.Page[Item], *errors.CustomError)
+	PurgeTrash(ctx context.Context) (Purged, *errors.CustomError)
+}
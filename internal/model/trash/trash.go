@@ -0,0 +1,29 @@
+package trash
+
+import "time"
+
+// DefaultRetention is how long deleted posts and users stay in the trash before they are purged
+const DefaultRetention = 30 * 24 * time.Hour
+
+// Type is the kind of resource of an item of the trash
+type Type string
+
+const (
+	TypePost Type = "post"
+	TypeUser Type = "user"
+)
+
+// Item is a deleted post or user. Name is the title of the post or the username of the user.
+type Item struct {
+	Type      Type      `json:"type"`
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// Purged counts the posts and users permanently removed by a purge of the trash.
+type Purged struct {
+	Posts int64 `json:"posts"`
+	Users int64 `json:"users"`
+}
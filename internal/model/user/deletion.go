@@ -0,0 +1,36 @@
+package user
+
+// GhostUsername is the username of the account that the 'anonymize' policy gives the posts and comments
+// of deleted users to. Usernames can't have brackets, so nobody can register it.
+const GhostUsername = "[deleted]"
+
+// DeletionPolicy decides what happens to the posts of a deleted user
+type DeletionPolicy string
+
+const (
+	// DeletionCascade moves the posts to the trash along with the user
+	DeletionCascade DeletionPolicy = "cascade"
+	// DeletionReassign gives the posts to another user
+	DeletionReassign DeletionPolicy = "reassign"
+	// DeletionAnonymize gives the posts and the comments to the ghost account
+	DeletionAnonymize DeletionPolicy = "anonymize"
+)
+
+// Deletion describes how a user is deleted. ReassignTo is the user that receives the posts with the
+// 'reassign' policy. A dry run reports what would be affected without changing anything.
+type Deletion struct {
+	Policy     DeletionPolicy
+	ReassignTo uint
+	DryRun     bool
+}
+
+// DeletionReport tells what the deletion of a user affected, or would affect in a dry run.
+type DeletionReport struct {
+	UserID     uint           `json:"user_id"`
+	Policy     DeletionPolicy `json:"policy"`
+	ReassignTo uint           `json:"reassign_to,omitempty"`
+	Posts      int64          `json:"posts"`
+	Comments   int64          `json:"comments"`
+	Sessions   int64          `json:"sessions"`
+	DryRun     bool           `json:"dry_run"`
+}
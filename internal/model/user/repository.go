@@ -1,14 +1,20 @@
 package user
 
-import "context"
+import (
+	"context"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/page"
+)
 
 // Repository handles the CRUD operations for User
 type Repository interface {
-	GetAll(ctx context.Context) ([]User, error)
+	GetAll(ctx context.Context, req page.Request) (page.Page[User], error)
 	GetById(ctx context.Context, id uint) (User, error)
 	GetByUsername(ctx context.Context, username string) (User, error)
 	GetByEmail(ctx context.Context, email string) (User, error)
 	Create(ctx context.Context, user *User) error
 	Update(ctx context.Context, id uint, user User) error
-	Delete(ctx context.Context, id uint) error
+	UpdateRole(ctx context.Context, id uint, version int, role Role) error
+	Delete(ctx context.Context, id uint, version int, deletion Deletion) (DeletionReport, error)
+	Restore(ctx context.Context, id uint) error
 }
@@ -0,0 +1,71 @@
+package user
+
+// Role defines what a user is allowed to do in the blog
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"
+	RoleEditor Role = "editor"
+	RoleAuthor Role = "author"
+)
+
+// Permission is an operation that can be granted to a role
+type Permission string
+
+const (
+	PermissionCreatePost       Permission = "posts:create"
+	PermissionEditOwnPost      Permission = "posts:edit:own"
+	PermissionEditAnyPost      Permission = "posts:edit:any"
+	PermissionPublishOwnPost   Permission = "posts:publish:own"
+	PermissionPublishAnyPost   Permission = "posts:publish:any"
+	PermissionDeleteOwnPost    Permission = "posts:delete:own"
+	PermissionDeleteAnyPost    Permission = "posts:delete:any"
+	PermissionModerateComments Permission = "comments:moderate"
+	PermissionManageUsers      Permission = "users:manage"
+	PermissionManageTrash      Permission = "trash:manage"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermissionCreatePost,
+		PermissionEditOwnPost, PermissionEditAnyPost,
+		PermissionPublishOwnPost, PermissionPublishAnyPost,
+		PermissionDeleteOwnPost, PermissionDeleteAnyPost,
+		PermissionModerateComments,
+		PermissionManageUsers,
+		PermissionManageTrash,
+	},
+	RoleEditor: {
+		PermissionCreatePost,
+		PermissionEditOwnPost, PermissionEditAnyPost,
+		PermissionPublishOwnPost, PermissionPublishAnyPost,
+		PermissionDeleteOwnPost,
+		PermissionModerateComments,
+	},
+	RoleAuthor: {
+		PermissionCreatePost,
+		PermissionEditOwnPost,
+		PermissionPublishOwnPost,
+		PermissionDeleteOwnPost,
+	},
+}
+
+// RoleUpdate is the body sent by an admin to change the role of a user.
+type RoleUpdate struct {
+	Role Role `json:"role"`
+}
+
+func (r Role) IsValid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether the role was granted the permission.
+func (r Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
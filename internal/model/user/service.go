@@ -3,15 +3,19 @@ package user
 import (
 	"context"
 
+	"github.com/cortzero/go-postgres-blog/internal/model/page"
 	"github.com/cortzero/go-postgres-blog/internal/service/errors"
 )
 
 // user.Service is the interface that a service layer component must fullfil to manage CRUD operations for users.
 type Service interface {
 	CreateUser(ctx context.Context, user *User) *errors.CustomError
-	UpdateUser(ctx context.Context, id uint, user *User) *errors.CustomError
-	DeleteUser(ctx context.Context, id uint) *errors.CustomError
-	GetAllUsers(ctx context.Context) ([]User, *errors.CustomError)
+	UpdateUser(ctx context.Context, id uint, user *User) (User, *errors.CustomError)
+	PatchUser(ctx context.Context, id uint, user *User) (User, *errors.CustomError)
+	UpdateUserRole(ctx context.Context, id uint, version int, role Role) (User, *errors.CustomError)
+	DeleteUser(ctx context.Context, id uint, version int, deletion Deletion) (DeletionReport, *errors.CustomError)
+	RestoreUser(ctx context.Context, id uint) (User, *errors.CustomError)
+	GetAllUsers(ctx context.Context, req page.Request) (page.Page[User], *errors.CustomError)
 	GetUserById(ctx context.Context, id uint) (User, *errors.CustomError)
 	GetUserByUsername(ctx context.Context, username string) (User, *errors.CustomError)
 	GetUserByEmail(ctx context.Context, email string) (User, *errors.CustomError)
@@ -1,11 +1,35 @@
 package user
 
 import (
+	"errors"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	// MinUsernameLength and MaxUsernameLength bound the number of characters of a username.
+	MinUsernameLength = 3
+	MaxUsernameLength = 30
+	// MaxNameLength is the maximum number of characters of the first and of the last name.
+	MaxNameLength = 150
+	// MaxEmailLength is the maximum number of characters of an email.
+	MaxEmailLength = 150
+	// MaxPictureLength is the maximum number of characters of the URL of the picture.
+	MaxPictureLength = 256
+	// MinPasswordLength is the minimum number of characters of a password.
+	MinPasswordLength = 8
+	// MaxPasswordBytes is the longest password bcrypt can hash.
+	MaxPasswordBytes = 72
+)
+
+// ErrUsernameTaken and ErrEmailTaken are returned by the repository when another user already has the
+// username or the email, whatever their case.
+var (
+	ErrUsernameTaken = errors.New("the username is already taken")
+	ErrEmailTaken    = errors.New("the email is already taken")
+)
+
 type User struct {
 	ID           uint      `json:"id,omitempty"`
 	FirstName    string    `json:"first_name,omitempty"`
@@ -15,10 +39,30 @@ type User struct {
 	Picture      string    `json:"picture,omitempty"`
 	Password     string    `json:"password,omitempty"`
 	PasswordHash string    `json:"-"`
+	Role         Role      `json:"role,omitempty"`
+	Version      int       `json:"version,omitempty"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// Profile is the part of a user that can be changed by its owner. PATCH requests are applied to it.
+type Profile struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Picture   string `json:"picture"`
+}
+
+// Profile returns the fields of the user that can be changed by its owner.
+func (u User) Profile() Profile {
+	return Profile{FirstName: u.FirstName, LastName: u.LastName, Email: u.Email, Picture: u.Picture}
+}
+
+// User returns a user with the fields of the profile.
+func (p Profile) User() User {
+	return User{FirstName: p.FirstName, LastName: p.LastName, Email: p.Email, Picture: p.Picture}
+}
+
 func (u *User) HashPassword() error {
 	passwordHash, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
 	if err != nil {
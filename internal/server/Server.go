@@ -1,17 +1,36 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/cortzero/go-postgres-blog/internal/data"
+	"github.com/cortzero/go-postgres-blog/internal/model/export"
+	"github.com/cortzero/go-postgres-blog/internal/model/trash"
 	"github.com/cortzero/go-postgres-blog/internal/server/handlers"
+	"github.com/cortzero/go-postgres-blog/internal/server/middleware"
+	"github.com/cortzero/go-postgres-blog/internal/service/jobs"
+	"github.com/cortzero/go-postgres-blog/internal/service/jwt"
 	"github.com/cortzero/go-postgres-blog/internal/service/services"
 )
 
+const (
+	defaultAccessTokenTTL    = 15 * time.Minute
+	defaultRefreshTokenTTL   = 30 * 24 * time.Hour
+	defaultSchedulerInterval = time.Minute
+	defaultPurgeInterval     = time.Hour
+	defaultExportInterval    = time.Minute
+	defaultSiteTitle         = "Blog"
+)
+
 // Server contains a server configuration
 type Server struct {
 	server *http.Server
+	jobs   []*jobs.Job
 }
 
 func New(host string, port string) *Server {
@@ -21,15 +40,65 @@ func New(host string, port string) *Server {
 	// User Service
 	userService := services.NewUserService(data.NewUserRepository(conn))
 
-	// User Handler
-	userHandler := handlers.NewUserHandler(userService)
-
 	// Post Service
 	postService := services.NewPostService(data.NewPostRepository(conn))
 
+	// User Handler
+	userHandler := handlers.NewUserHandler(userService, postService)
+
 	// Post Handler
 	postHandler := handlers.NewPostHandler(postService)
 
+	// Tag Service
+	tagService := services.NewTagService(data.NewTagRepository(conn))
+
+	// Tag Handler
+	tagHandler := handlers.NewTagHandler(tagService, postService)
+
+	// Comment Service
+	commentService := services.NewCommentService(data.NewCommentRepository(conn), data.NewPostRepository(conn))
+
+	// Comment Handler
+	commentHandler := handlers.NewCommentHandler(commentService)
+
+	// Feed Handler
+	feedHandler := handlers.NewFeedHandler(
+		postService,
+		userService,
+		envOrDefault("SITE_TITLE", defaultSiteTitle),
+		os.Getenv("SITE_DESCRIPTION"),
+		os.Getenv("SITE_URL"),
+	)
+
+	// Trash Service
+	trashService := services.NewTrashService(
+		data.NewTrashRepository(conn),
+		durationFromEnv("TRASH_RETENTION", trash.DefaultRetention),
+	)
+
+	// Trash Handler
+	trashHandler := handlers.NewTrashHandler(trashService)
+
+	// Export Service
+	exportService := services.NewExportService(
+		data.NewExportRepository(conn),
+		durationFromEnv("EXPORT_EXPIRY", export.DefaultExpiry),
+	)
+
+	// Export Handler
+	exportHandler := handlers.NewExportHandler(exportService)
+
+	// Auth Service
+	authService := services.NewAuthService(
+		userService,
+		data.NewRefreshTokenRepository(conn),
+		newTokenManager(),
+		durationFromEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL),
+	)
+
+	// Auth Handler
+	authHandler := handlers.NewAuthHandler(authService)
+
 	// Creating the Server Mux
 	mux := http.NewServeMux()
 
@@ -44,21 +113,122 @@ func New(host string, port string) *Server {
 	mux.Handle("/api/v1/posts/", postHandler)
 	mux.Handle("/api/v1/posts/{id}", postHandler)
 	mux.Handle("/api/v1/posts/{id}/", postHandler)
+	mux.Handle("/api/v1/posts/{id}/revisions/{rev}/restore", postHandler)
+
+	// Mapping Tag endpoints to the tag handler
+	mux.Handle("/api/v1/tags", tagHandler)
+	mux.Handle("/api/v1/tags/", tagHandler)
+	mux.Handle("/api/v1/tags/{slug}/posts", tagHandler)
+
+	// Mapping Comment endpoints to the comment handler
+	mux.Handle("/api/v1/posts/{id}/comments", commentHandler)
+	mux.Handle("/api/v1/posts/{id}/comments/", commentHandler)
+	mux.Handle("/api/v1/posts/{id}/comments/{commentId}", commentHandler)
+	mux.Handle("/api/v1/posts/{id}/comments/{commentId}/", commentHandler)
+
+	// Mapping Feed endpoints to the feed handler
+	mux.Handle("/feeds/", feedHandler)
+	mux.Handle("/api/v1/users/{id}/feed.xml", feedHandler)
+
+	// Mapping Trash endpoints to the trash handler
+	mux.Handle("/api/v1/trash", trashHandler)
+
+	// Mapping Export endpoints to the export handler
+	mux.Handle("/api/v1/users/{id}/export", exportHandler)
+	mux.Handle("/api/v1/users/{id}/exports/{exportId}", exportHandler)
+	mux.Handle("/api/v1/users/{id}/exports/{exportId}/download", exportHandler)
+
+	// Mapping Auth endpoints to the auth handler
+	mux.Handle("/api/v1/auth/", authHandler)
+
+	// Background jobs
+	publishScheduled := jobs.New("publish-scheduled-posts", durationFromEnv("SCHEDULER_INTERVAL", defaultSchedulerInterval),
+		func(ctx context.Context) error {
+			published, err := postService.PublishDuePosts(ctx)
+			if err != nil {
+				return fmt.Errorf("%s %s", err.Message, err.Details)
+			}
+			if published > 0 {
+				log.Printf("Published %d scheduled posts", published)
+			}
+			return nil
+		})
+
+	purgeTrash := jobs.New("purge-trash", durationFromEnv("PURGE_INTERVAL", defaultPurgeInterval),
+		func(ctx context.Context) error {
+			purged, err := trashService.PurgeTrash(ctx)
+			if err != nil {
+				return fmt.Errorf("%s %s", err.Message, err.Details)
+			}
+			if purged.Posts > 0 || purged.Users > 0 {
+				log.Printf("Purged %d posts and %d users from the trash", purged.Posts, purged.Users)
+			}
+			return nil
+		})
+
+	buildExports := jobs.New("build-exports", durationFromEnv("EXPORT_INTERVAL", defaultExportInterval),
+		func(ctx context.Context) error {
+			produced, err := exportService.ProcessExports(ctx)
+			if err != nil {
+				return fmt.Errorf("%s %s", err.Message, err.Details)
+			}
+			if produced > 0 {
+				log.Printf("Produced %d user exports", produced)
+			}
+			return nil
+		})
 
 	return &Server{
 		server: &http.Server{
 			Addr:    host + ":" + port,
-			Handler: mux,
+			Handler: middleware.RequestID(middleware.Authentication(authService, mux)),
 		},
+		jobs: []*jobs.Job{publishScheduled, purgeTrash, buildExports},
+	}
+}
+
+// newTokenManager creates the access token manager from the JWT_SECRET and ACCESS_TOKEN_TTL environment variables.
+func newTokenManager() *jwt.Manager {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		log.Fatal("the JWT_SECRET environment variable is not set")
 	}
+	return jwt.NewManager(secret, durationFromEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTTL))
+}
+
+// envOrDefault returns the value of the environment variable, or the default when it is not set.
+func envOrDefault(name string, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// durationFromEnv parses the environment variable as a time.Duration, falling back to the default when it is not set.
+func durationFromEnv(name string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid %s '%s': %v", name, value, err)
+	}
+	return parsed
 }
 
 func (serv *Server) Start() {
+	for _, job := range serv.jobs {
+		job.Start()
+	}
 	log.Printf("Server running on http://%s", serv.server.Addr)
 	log.Fatal(serv.server.ListenAndServe())
 }
 
 func (serv *Server) Close() error {
 	// TODO: add resource closure
+	for _, job := range serv.jobs {
+		job.Stop()
+	}
 	return nil
 }
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/auth"
+	"github.com/cortzero/go-postgres-blog/internal/server/response"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+)
+
+var (
+	authLoginUrlRegExp   = regexp.MustCompile(`^/api/v1/auth/login$`)
+	authRefreshUrlRegExp = regexp.MustCompile(`^/api/v1/auth/refresh$`)
+	authLogoutUrlRegExp  = regexp.MustCompile(`^/api/v1/auth/logout$`)
+)
+
+type AuthHandler struct {
+	Service auth.Service
+}
+
+func NewAuthHandler(service auth.Service) *AuthHandler {
+	return &AuthHandler{
+		Service: service,
+	}
+}
+
+func (handler *AuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reqURL := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case r.Method == http.MethodPost && authLoginUrlRegExp.MatchString(reqURL):
+		handler.LoginHandler(w, r)
+		return
+	case r.Method == http.MethodPost && authRefreshUrlRegExp.MatchString(reqURL):
+		handler.RefreshHandler(w, r)
+		return
+	case r.Method == http.MethodPost && authLogoutUrlRegExp.MatchString(reqURL):
+		handler.LogoutHandler(w, r)
+		return
+	default:
+		newError := errors.NewCustomError(
+			errors.NotFound,
+			"NOT_FOUND",
+			"Could not found the requested URL.",
+			fmt.Sprintf("The URL '%s' does not exist.", r.URL.Path),
+			time.Now())
+		response.WriteError(w, r, newError)
+		return
+	}
+}
+
+func (handler *AuthHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	var credentials auth.Credentials
+	err := json.NewDecoder(r.Body).Decode(&credentials)
+	if err != nil {
+		newError := errors.NewCustomError(
+			errors.Malformed,
+			"BAD_REQUEST",
+			"The request is malformed.",
+			"The body of the request may have an incorrect format.",
+			time.Now())
+		response.WriteError(w, r, newError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	ctx := r.Context()
+	token, err_login := handler.Service.Login(ctx, credentials)
+	if err_login != nil {
+		response.WriteError(w, r, err_login)
+		return
+	}
+
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"token": token})
+}
+
+func (handler *AuthHandler) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	refreshToken, ok := decodeRefreshToken(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	token, err_refresh := handler.Service.Refresh(ctx, refreshToken)
+	if err_refresh != nil {
+		response.WriteError(w, r, err_refresh)
+		return
+	}
+
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"token": token})
+}
+
+func (handler *AuthHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	refreshToken, ok := decodeRefreshToken(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	err_logout := handler.Service.Logout(ctx, refreshToken)
+	if err_logout != nil {
+		response.WriteError(w, r, err_logout)
+		return
+	}
+
+	response.EncodeDataToJSON(w, r, http.StatusNoContent, nil)
+}
+
+// decodeRefreshToken reads the refresh token from the body of the request. If it is missing
+// it writes a 400 response and returns false.
+func decodeRefreshToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var body auth.RefreshRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.RefreshToken == "" {
+		newError := errors.NewCustomError(
+			errors.Malformed,
+			"BAD_REQUEST",
+			"The request is malformed.",
+			"The body of the request must contain the 'refresh_token' field.",
+			time.Now())
+		response.WriteError(w, r, newError)
+		return "", false
+	}
+
+	defer r.Body.Close()
+
+	return body.RefreshToken, true
+}
+
+// authenticatedPrincipal returns the user that made the request. If the request is anonymous
+// it writes a 401 response and returns false.
+func authenticatedPrincipal(w http.ResponseWriter, r *http.Request) (auth.Principal, bool) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		newError := errors.NewCustomError(
+			errors.Unauthorized,
+			"UNAUTHORIZED",
+			"You must be logged in to perform this operation.",
+			"Send a valid access token in the 'Authorization' header using the Bearer scheme.",
+			time.Now())
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+		response.WriteError(w, r, newError)
+		return auth.Principal{}, false
+	}
+	return principal, true
+}
+
+// forbidden writes a 403 response for an operation the authenticated user is not allowed to perform.
+func forbidden(w http.ResponseWriter, r *http.Request, message string) {
+	newError := errors.NewCustomError(
+		errors.Forbidden,
+		"FORBIDDEN",
+		message,
+		"Your role does not grant the permission required by this operation.",
+		time.Now())
+	response.WriteError(w, r, newError)
+}
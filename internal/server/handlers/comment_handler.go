@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/comment"
+	"github.com/cortzero/go-postgres-blog/internal/server/response"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+)
+
+var (
+	commentsUrlRegExpNoVars = regexp.MustCompile(`^/api/v1/posts/(\d+)/comments$`)
+	commentsUrlRegExpVars   = regexp.MustCompile(`^/api/v1/posts/(\d+)/comments/(\d+)$`)
+)
+
+type CommentHandler struct {
+	Service comment.Service
+}
+
+func NewCommentHandler(service comment.Service) *CommentHandler {
+	return &CommentHandler{
+		Service: service,
+	}
+}
+
+func (handler *CommentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reqURL := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case r.Method == http.MethodGet && commentsUrlRegExpNoVars.MatchString(reqURL):
+		handler.GetTreeHandler(w, r)
+		return
+	case r.Method == http.MethodPost && commentsUrlRegExpNoVars.MatchString(reqURL):
+		handler.CreateHandler(w, r)
+		return
+	case r.Method == http.MethodPut && commentsUrlRegExpVars.MatchString(reqURL):
+		handler.UpdateHandler(w, r)
+		return
+	case r.Method == http.MethodDelete && commentsUrlRegExpVars.MatchString(reqURL):
+		handler.DeleteHandler(w, r)
+		return
+	default:
+		newError := errors.NewCustomError(
+			errors.NotFound,
+			"NOT_FOUND",
+			"Could not found the requested URL.",
+			fmt.Sprintf("The URL '%s' does not exist.", r.URL.Path),
+			time.Now())
+		response.WriteError(w, r, newError)
+		return
+	}
+}
+
+func (handler *CommentHandler) GetTreeHandler(w http.ResponseWriter, r *http.Request) {
+	postId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return
+	}
+
+	depth := comment.MaxDepth
+	if depthStr := r.URL.Query().Get("depth"); depthStr != "" {
+		value, err := strconv.Atoi(depthStr)
+		if err != nil || value < 0 {
+			newError := errors.NewCustomError(
+				errors.Malformed,
+				"BAD_REQUEST",
+				"The query parameters are not valid.",
+				fmt.Sprintf("The query parameter 'depth' must be an integer between 0 and %d.", comment.MaxDepth),
+				time.Now())
+			response.WriteError(w, r, newError)
+			return
+		}
+		depth = value
+	}
+
+	ctx := r.Context()
+	comments, err := handler.Service.GetCommentTree(ctx, postId, depth)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"comments": comments})
+}
+
+func (handler *CommentHandler) CreateHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authenticatedPrincipal(w, r); !ok {
+		return
+	}
+
+	postId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var c comment.Comment
+	if !decodeComment(w, r, &c) {
+		return
+	}
+
+	ctx := r.Context()
+	error_creating := handler.Service.CreateComment(ctx, postId, &c)
+	if error_creating != nil {
+		response.WriteError(w, r, error_creating)
+		return
+	}
+
+	w.Header().Add("Location", fmt.Sprintf("%s/%d", strings.TrimSuffix(r.URL.Path, "/"), c.ID))
+	response.EncodeDataToJSON(w, r, http.StatusCreated, response.Map{"commentCreated": c})
+}
+
+func (handler *CommentHandler) UpdateHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authenticatedPrincipal(w, r); !ok {
+		return
+	}
+
+	postId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return
+	}
+	commentId, ok := parseIdPathValue(w, r, "commentId")
+	if !ok {
+		return
+	}
+
+	var c comment.Comment
+	if !decodeComment(w, r, &c) {
+		return
+	}
+
+	ctx := r.Context()
+	error_updating := handler.Service.UpdateComment(ctx, postId, commentId, &c)
+	if error_updating != nil {
+		response.WriteError(w, r, error_updating)
+		return
+	}
+
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"comment": c})
+}
+
+func (handler *CommentHandler) DeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authenticatedPrincipal(w, r); !ok {
+		return
+	}
+
+	postId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return
+	}
+	commentId, ok := parseIdPathValue(w, r, "commentId")
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	error_deleting := handler.Service.DeleteComment(ctx, postId, commentId)
+	if error_deleting != nil {
+		response.WriteError(w, r, error_deleting)
+		return
+	}
+
+	response.EncodeDataToJSON(w, r, http.StatusOK, nil)
+}
+
+func decodeComment(w http.ResponseWriter, r *http.Request, c *comment.Comment) bool {
+	err := json.NewDecoder(r.Body).Decode(c)
+	if err != nil {
+		newError := errors.NewCustomError(
+			errors.Malformed,
+			"BAD_REQUEST",
+			"The request is malformed.",
+			"The body of the request may have an incorrect format.",
+			time.Now())
+		response.WriteError(w, r, newError)
+		return false
+	}
+
+	defer r.Body.Close()
+
+	return true
+}
+
+// parseIdPathValue converts the path variable to an id. If it is not a number it writes a 400 response and returns false.
+func parseIdPathValue(w http.ResponseWriter, r *http.Request, name string) (uint, bool) {
+	idStr := r.PathValue(name)
+
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		newError := errors.NewCustomError(
+			errors.Malformed,
+			"BAD_REQUEST",
+			fmt.Sprintf("Error parsing the path variable '%s' on the URL", idStr),
+			err.Error(),
+			time.Now())
+		response.WriteError(w, r, newError)
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/server/response"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+)
+
+// etag returns the entity tag of a version of a resource.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// notModified sets the ETag of the version on the response and, when the If-None-Match header of the
+// request matches it, answers 304 Not Modified and returns true.
+func notModified(w http.ResponseWriter, r *http.Request, version int) bool {
+	tag := etag(version)
+	w.Header().Set("ETag", tag)
+
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	// If-None-Match uses the weak comparison, so W/ prefixes are ignored
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion returns the version required by the If-Match header of the request, or 0 when the
+// header is missing or '*'. Tags that are weak or not returned by this API can't match any version, so
+// the request is answered with 412 Precondition Failed. In both error cases it returns false.
+func ifMatchVersion(w http.ResponseWriter, r *http.Request) (int, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	if strings.Contains(header, ",") {
+		newError := errors.NewCustomError(
+			errors.Malformed,
+			"BAD_REQUEST",
+			"The If-Match header is not valid.",
+			"The If-Match header must be '*' or a single ETag returned by this API.",
+			time.Now())
+		response.WriteError(w, r, newError)
+		return 0, false
+	}
+
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(header, `"`), `"`))
+	if err != nil || version <= 0 || etag(version) != header {
+		newError := errors.NewCustomError(
+			errors.PreconditionFailed,
+			"VERSION_MISMATCH",
+			"The If-Match header does not match the resource.",
+			"The If-Match header must be an ETag returned by this API. Get the resource again and retry the change.",
+			time.Now())
+		response.WriteError(w, r, newError)
+		return 0, false
+	}
+	return version, true
+}
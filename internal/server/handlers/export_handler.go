@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/export"
+	"github.com/cortzero/go-postgres-blog/internal/server/response"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+	"github.com/cortzero/go-postgres-blog/internal/service/policy"
+)
+
+var (
+	exportsUrlRegExpNew      = regexp.MustCompile(`^/api/v1/users/(\d+)/export$`)
+	exportsUrlRegExpVars     = regexp.MustCompile(`^/api/v1/users/(\d+)/exports/(\d+)$`)
+	exportsUrlRegExpDownload = regexp.MustCompile(`^/api/v1/users/(\d+)/exports/(\d+)/download$`)
+)
+
+// ExportHandler serves the archives of the personal data of the users. Large accounts are exported in the
+// background, and their exports are polled until they can be downloaded.
+type ExportHandler struct {
+	Service export.Service
+}
+
+func NewExportHandler(service export.Service) *ExportHandler {
+	return &ExportHandler{
+		Service: service,
+	}
+}
+
+func (handler *ExportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reqURL := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case r.Method == http.MethodGet && exportsUrlRegExpNew.MatchString(reqURL):
+		handler.ExportHandler(w, r)
+		return
+	case r.Method == http.MethodGet && exportsUrlRegExpVars.MatchString(reqURL):
+		handler.GetByIdHandler(w, r)
+		return
+	case r.Method == http.MethodGet && exportsUrlRegExpDownload.MatchString(reqURL):
+		handler.DownloadHandler(w, r)
+		return
+	default:
+		newError := errors.NewCustomError(
+			errors.NotFound,
+			"NOT_FOUND",
+			"Could not found the requested URL.",
+			fmt.Sprintf("The URL '%s' does not exist.", r.URL.Path),
+			time.Now())
+		response.WriteError(w, r, newError)
+		return
+	}
+}
+
+// ExportHandler answers with the ZIP archive of the user, or with 202 and the export being produced in the
+// background when the account is too large, whose status is at the URL of the Location header.
+func (handler *ExportHandler) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	userId, ok := handler.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	archive, pending, err := handler.Service.ExportUser(ctx, userId)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	if archive == nil {
+		w.Header().Set("Location", exportURL(pending))
+		response.EncodeDataToJSON(w, r, http.StatusAccepted, response.Map{"export": pending})
+		return
+	}
+	writeArchive(w, userId, archive)
+}
+
+// GetByIdHandler returns the status of an export, along with the URL of its archive once it is ready.
+func (handler *ExportHandler) GetByIdHandler(w http.ResponseWriter, r *http.Request) {
+	userId, ok := handler.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	exportId, ok := parseIdPathValue(w, r, "exportId")
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	e, err := handler.Service.GetExport(ctx, userId, exportId)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	body := response.Map{"export": e}
+	if e.Status == export.StatusReady {
+		body["download_url"] = exportURL(e) + "/download"
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	response.EncodeDataToJSON(w, r, http.StatusOK, body)
+}
+
+// DownloadHandler answers with the ZIP archive of an export that is ready.
+func (handler *ExportHandler) DownloadHandler(w http.ResponseWriter, r *http.Request) {
+	userId, ok := handler.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	exportId, ok := parseIdPathValue(w, r, "exportId")
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	archive, err := handler.Service.GetArchive(ctx, userId, exportId)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	writeArchive(w, userId, archive)
+}
+
+// authorize returns the id of the user being exported when the authenticated user can export it.
+// Otherwise it writes the error response and returns false.
+func (handler *ExportHandler) authorize(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	principal, ok := authenticatedPrincipal(w, r)
+	if !ok {
+		return 0, false
+	}
+
+	userId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return 0, false
+	}
+
+	if !policy.CanManageUser(principal, userId) {
+		forbidden(w, r, "You are not allowed to export this user.")
+		return 0, false
+	}
+	return userId, true
+}
+
+func exportURL(e export.Export) string {
+	return fmt.Sprintf("/api/v1/users/%d/exports/%d", e.UserID, e.ID)
+}
+
+// writeArchive sends the archive as a download. Personal data is never stored by caches.
+func writeArchive(w http.ResponseWriter, userId uint, archive []byte) {
+	filename := fmt.Sprintf("user-%d-export-%s.zip", userId, time.Now().UTC().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/page"
+	"github.com/cortzero/go-postgres-blog/internal/model/post"
+	"github.com/cortzero/go-postgres-blog/internal/model/user"
+	"github.com/cortzero/go-postgres-blog/internal/server/response"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+	"github.com/cortzero/go-postgres-blog/internal/service/feed"
+)
+
+var (
+	feedsUrlRegExpRSS    = regexp.MustCompile(`^/feeds/rss\.xml$`)
+	feedsUrlRegExpAtom   = regexp.MustCompile(`^/feeds/atom\.xml$`)
+	feedsUrlRegExpJSON   = regexp.MustCompile(`^/feeds/feed\.json$`)
+	feedsUrlRegExpAuthor = regexp.MustCompile(`^/api/v1/users/(\d+)/feed\.xml$`)
+)
+
+// FeedHandler serves the feeds of the latest published posts of the blog and of each author.
+// SiteURL is the address of the frontend, where the posts are linked at '/posts/{slug}'. When it is
+// empty the address of the request is used, and the feeds can only be cached by the client.
+type FeedHandler struct {
+	PostService post.Service
+	UserService user.Service
+	Title       string
+	Description string
+	SiteURL     string
+}
+
+func NewFeedHandler(postService post.Service, userService user.Service, title string, description string, siteURL string) *FeedHandler {
+	return &FeedHandler{
+		PostService: postService,
+		UserService: userService,
+		Title:       title,
+		Description: description,
+		SiteURL:     strings.TrimSuffix(siteURL, "/"),
+	}
+}
+
+func (handler *FeedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reqURL := r.URL.Path
+	get := r.Method == http.MethodGet || r.Method == http.MethodHead
+	switch {
+	case get && feedsUrlRegExpRSS.MatchString(reqURL):
+		handler.SiteFeedHandler(w, r, feed.RSS, "application/rss+xml; charset=utf-8")
+		return
+	case get && feedsUrlRegExpAtom.MatchString(reqURL):
+		handler.SiteFeedHandler(w, r, feed.Atom, "application/atom+xml; charset=utf-8")
+		return
+	case get && feedsUrlRegExpJSON.MatchString(reqURL):
+		handler.SiteFeedHandler(w, r, feed.JSON, "application/feed+json; charset=utf-8")
+		return
+	case get && feedsUrlRegExpAuthor.MatchString(reqURL):
+		handler.AuthorFeedHandler(w, r)
+		return
+	default:
+		newError := errors.NewCustomError(
+			errors.NotFound,
+			"NOT_FOUND",
+			"Could not found the requested URL.",
+			fmt.Sprintf("The URL '%s' does not exist.", r.URL.Path),
+			time.Now())
+		response.WriteError(w, r, newError)
+		return
+	}
+}
+
+// SiteFeedHandler serves the latest published posts of every author in the format.
+func (handler *FeedHandler) SiteFeedHandler(w http.ResponseWriter, r *http.Request, format func(feed.Channel) ([]byte, error), contentType string) {
+	ctx := r.Context()
+	posts, err := handler.PostService.GetAllPosts(ctx, post.Query{
+		Status: post.StatusPublished,
+		Page:   page.Request{Limit: feed.DefaultSize},
+	})
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	siteURL := handler.siteURL(r)
+	channel := feed.Channel{
+		Title:       handler.Title,
+		Description: handler.Description,
+		SiteURL:     siteURL,
+		FeedURL:     siteURL + r.URL.Path,
+		Items:       handler.feedItems(r, siteURL, posts.Items),
+	}
+	handler.serveFeed(w, r, channel, format, contentType)
+}
+
+// AuthorFeedHandler serves the latest published posts of the user as RSS.
+func (handler *FeedHandler) AuthorFeedHandler(w http.ResponseWriter, r *http.Request) {
+	userId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	author, error_user := handler.UserService.GetUserById(ctx, userId)
+	if error_user != nil {
+		response.WriteError(w, r, error_user)
+		return
+	}
+
+	// Feeds only have published posts, even when authors read their own
+	posts, error_posts := handler.PostService.GetAllPosts(ctx, post.Query{
+		AuthorID: &userId,
+		Status:   post.StatusPublished,
+		Page:     page.Request{Limit: feed.DefaultSize},
+	})
+	if error_posts != nil {
+		response.WriteError(w, r, error_posts)
+		return
+	}
+
+	siteURL := handler.siteURL(r)
+	channel := feed.Channel{
+		Title:       fmt.Sprintf("%s: %s", handler.Title, authorName(author)),
+		Description: fmt.Sprintf("The latest posts of %s.", authorName(author)),
+		SiteURL:     siteURL,
+		FeedURL:     siteURL + r.URL.Path,
+		Items:       handler.feedItems(r, siteURL, posts.Items),
+	}
+	handler.serveFeed(w, r, channel, feed.RSS, "application/rss+xml; charset=utf-8")
+}
+
+// serveFeed writes the feed with an ETag computed from its content and the time of its latest change as
+// Last-Modified, answering conditional requests with 304 Not Modified.
+func (handler *FeedHandler) serveFeed(w http.ResponseWriter, r *http.Request, channel feed.Channel, format func(feed.Channel) ([]byte, error), contentType string) {
+	body, err := format(channel)
+	if err != nil {
+		newError := errors.NewCustomError(
+			errors.Internal,
+			"ERROR_GENERATING_FEED",
+			"An error occurred while generating the feed.",
+			err.Error(),
+			time.Now())
+		response.WriteError(w, r, newError)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	// Links built from the Host header of the request must not be stored by shared caches
+	if handler.SiteURL != "" {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+	} else {
+		w.Header().Set("Cache-Control", "private, max-age=300")
+	}
+	http.ServeContent(w, r, "", channel.LastModified(), bytes.NewReader(body))
+}
+
+func (handler *FeedHandler) feedItems(r *http.Request, siteURL string, posts []post.Post) []feed.Item {
+	// Posts of the same author share the lookup of the name
+	authors := make(map[uint]string)
+	items := make([]feed.Item, 0, len(posts))
+	for _, p := range posts {
+		name, ok := authors[p.UserID]
+		if !ok {
+			if author, err := handler.UserService.GetUserById(r.Context(), p.UserID); err == nil {
+				name = authorName(author)
+			}
+			authors[p.UserID] = name
+		}
+
+		published := p.CreatedAt
+		if p.PublishedAt != nil {
+			published = *p.PublishedAt
+		}
+		updated := p.UpdatedAt
+		if updated.Before(published) {
+			updated = published
+		}
+
+		items = append(items, feed.Item{
+			ID:          fmt.Sprintf("%s/posts/%d", siteURL, p.ID),
+			URL:         fmt.Sprintf("%s/posts/%s", siteURL, url.PathEscape(p.Slug)),
+			Title:       p.Title,
+			ContentHTML: p.BodyHTML,
+			Author:      name,
+			Tags:        p.Tags,
+			Published:   published,
+			Updated:     updated,
+		})
+	}
+	return items
+}
+
+func (handler *FeedHandler) siteURL(r *http.Request) string {
+	if handler.SiteURL != "" {
+		return handler.SiteURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func authorName(u user.User) string {
+	if name := strings.TrimSpace(u.FirstName + " " + u.LastName); name != "" {
+		return name
+	}
+	return u.Username
+}
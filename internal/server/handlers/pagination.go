@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/page"
+	"github.com/cortzero/go-postgres-blog/internal/server/response"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+)
+
+// parsePageRequest reads the 'limit', 'offset' and 'cursor' query parameters. If any of them is
+// invalid it writes a 400 response and returns false.
+func parsePageRequest(w http.ResponseWriter, r *http.Request) (page.Request, bool) {
+	query := r.URL.Query()
+	var req page.Request
+
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"limit", &req.Limit}, {"offset", &req.Offset}} {
+		str := query.Get(param.name)
+		if str == "" {
+			continue
+		}
+		value, err := strconv.Atoi(str)
+		if err != nil || value < 0 {
+			badPageRequest(w, r, fmt.Sprintf("The query parameter '%s' must be a non-negative integer.", param.name))
+			return page.Request{}, false
+		}
+		*param.value = value
+	}
+
+	if str := query.Get("cursor"); str != "" {
+		cursor, err := page.DecodeCursor(str)
+		if err != nil {
+			badPageRequest(w, r, "The query parameter 'cursor' is not a cursor returned by this API.")
+			return page.Request{}, false
+		}
+		req.Cursor = &cursor
+	}
+
+	return req.Normalized(), true
+}
+
+func badPageRequest(w http.ResponseWriter, r *http.Request, details string) {
+	newError := errors.NewCustomError(
+		errors.Malformed,
+		"BAD_REQUEST",
+		"The pagination parameters are not valid.",
+		details,
+		time.Now())
+	response.WriteError(w, r, newError)
+}
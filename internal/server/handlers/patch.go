@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/server/response"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+	"github.com/cortzero/go-postgres-blog/internal/service/patch"
+)
+
+// acceptPatch lists the media types of the patches accepted by the PATCH endpoints
+var acceptPatch = patch.MergePatchMediaType + ", " + patch.JSONPatchMediaType
+
+// applyPatch applies the JSON Merge Patch or JSON Patch in the body of the request, as told by its
+// Content-Type, to the document and decodes the result into dest. The patch can't add fields the
+// document does not have. If the patch can't be applied it writes the error response and returns false.
+func applyPatch(w http.ResponseWriter, r *http.Request, document any, dest any) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var apply func([]byte, []byte) ([]byte, error)
+	switch mediaType {
+	case patch.MergePatchMediaType:
+		apply = patch.Merge
+	case patch.JSONPatchMediaType:
+		apply = patch.Apply
+	default:
+		w.Header().Set("Accept-Patch", acceptPatch)
+		newError := errors.NewCustomError(
+			errors.Unsupported,
+			"UNSUPPORTED_PATCH_FORMAT",
+			"The format of the patch is not supported.",
+			fmt.Sprintf("The Content-Type must be one of %s.", acceptPatch),
+			time.Now())
+		response.WriteError(w, r, newError)
+		return false
+	}
+
+	body, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		invalidPatch(w, r, errors.Malformed, "INVALID_PATCH", "The patch can't be read.", err)
+		return false
+	}
+
+	doc, err := json.Marshal(document)
+	if err != nil {
+		invalidPatch(w, r, errors.Internal, "ERROR_APPLYING_PATCH", "An error occurred while applying the patch.", err)
+		return false
+	}
+
+	patched, err := apply(doc, body)
+	switch {
+	case err == nil:
+	case errors.Is(err, patch.ErrTestFailed):
+		invalidPatch(w, r, errors.Conflict, "PATCH_TEST_FAILED", "A test of the patch failed.", err)
+		return false
+	case errors.Is(err, patch.ErrPathNotFound):
+		invalidPatch(w, r, errors.Validation, "PATCH_NOT_APPLICABLE", "The patch can't be applied to the resource.", err)
+		return false
+	default:
+		invalidPatch(w, r, errors.Malformed, "INVALID_PATCH", "The patch is not valid.", err)
+		return false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dest); err != nil {
+		invalidPatch(w, r, errors.Validation, "PATCH_NOT_APPLICABLE", "The patch can't be applied to the resource.", err)
+		return false
+	}
+	return true
+}
+
+func invalidPatch(w http.ResponseWriter, r *http.Request, kind errors.Kind, errorType string, message string, err error) {
+	newError := errors.NewCustomError(
+		kind,
+		errorType,
+		message,
+		err.Error(),
+		time.Now())
+	response.WriteError(w, r, newError)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
@@ -12,11 +13,20 @@ import (
 	"github.com/cortzero/go-postgres-blog/internal/model/post"
 	"github.com/cortzero/go-postgres-blog/internal/server/response"
 	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+	"github.com/cortzero/go-postgres-blog/internal/service/policy"
 )
 
 var (
 	postsUrlRegExpNoVars = regexp.MustCompile(`^/api/v1/posts$`)
 	postsUrlRegExpVars   = regexp.MustCompile(`^/api/v1/posts/(\d+)$`)
+	postsUrlRegExpSearch = regexp.MustCompile(`^/api/v1/posts/search$`)
+	postsUrlRegExpSlug   = regexp.MustCompile(`^/api/v1/posts/by-slug/([^/]+)$`)
+	postsUrlRegExpStatus = regexp.MustCompile(`^/api/v1/posts/(\d+)/status$`)
+	postsUrlRegExpTrash  = regexp.MustCompile(`^/api/v1/posts/(\d+)/restore$`)
+
+	postsUrlRegExpRevisions = regexp.MustCompile(`^/api/v1/posts/(\d+)/revisions$`)
+	postsUrlRegExpDiff      = regexp.MustCompile(`^/api/v1/posts/(\d+)/revisions/diff$`)
+	postsUrlRegExpRestore   = regexp.MustCompile(`^/api/v1/posts/(\d+)/revisions/(\d+)/restore$`)
 )
 
 type PostHandler struct {
@@ -35,37 +45,90 @@ func (handler *PostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case r.Method == http.MethodGet && postsUrlRegExpNoVars.MatchString(reqURL):
 		handler.GetAllHandler(w, r)
 		return
+	case r.Method == http.MethodGet && postsUrlRegExpSearch.MatchString(reqURL):
+		handler.SearchHandler(w, r)
+		return
 	case r.Method == http.MethodGet && postsUrlRegExpVars.MatchString(reqURL):
 		handler.GetByIdHandler(w, r)
 		return
+	case r.Method == http.MethodGet && postsUrlRegExpSlug.MatchString(reqURL):
+		handler.GetBySlugHandler(w, r, postsUrlRegExpSlug.FindStringSubmatch(reqURL)[1])
+		return
 	case r.Method == http.MethodPost && postsUrlRegExpNoVars.MatchString(reqURL):
 		handler.CreateHandler(w, r)
 		return
 	case r.Method == http.MethodPut && postsUrlRegExpVars.MatchString(reqURL):
 		handler.UpdateHandler(w, r)
 		return
+	case r.Method == http.MethodPatch && postsUrlRegExpVars.MatchString(reqURL):
+		handler.PatchHandler(w, r)
+		return
 	case r.Method == http.MethodDelete && postsUrlRegExpVars.MatchString(reqURL):
 		handler.DeleteHandler(w, r)
 		return
+	case r.Method == http.MethodPut && postsUrlRegExpStatus.MatchString(reqURL):
+		handler.ChangeStatusHandler(w, r)
+		return
+	case r.Method == http.MethodPost && postsUrlRegExpTrash.MatchString(reqURL):
+		handler.RestoreHandler(w, r)
+		return
+	case r.Method == http.MethodGet && postsUrlRegExpRevisions.MatchString(reqURL):
+		handler.GetRevisionsHandler(w, r)
+		return
+	case r.Method == http.MethodGet && postsUrlRegExpDiff.MatchString(reqURL):
+		handler.DiffRevisionsHandler(w, r)
+		return
+	case r.Method == http.MethodPost && postsUrlRegExpRestore.MatchString(reqURL):
+		handler.RestoreRevisionHandler(w, r)
+		return
 	default:
-		fmt.Println("Not Found: " + r.URL.Path)
+		newError := errors.NewCustomError(
+			errors.NotFound,
+			"NOT_FOUND",
+			"Could not found the requested URL.",
+			fmt.Sprintf("The URL '%s' does not exist.", r.URL.Path),
+			time.Now())
+		response.WriteError(w, r, newError)
 		return
 	}
 }
 
 func (handler *PostHandler) GetAllHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	query, ok := parsePostQuery(w, r)
+	if !ok {
+		return
+	}
 
-	posts, err := handler.Service.GetAllPosts(ctx)
+	ctx := r.Context()
+	posts, err := handler.Service.GetAllPosts(ctx, query)
 	if err != nil {
-		response.CreateErrorResponse(w, r, http.StatusBadRequest, err, r.URL.Path)
+		response.WriteError(w, r, err)
+		return
+	}
+
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{
+		"posts":      posts.Items,
+		"pagination": response.NewPagination(r, query.Page, posts),
+	})
+}
+
+func (handler *PostHandler) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := parsePageRequest(w, r)
+	if !ok {
 		return
 	}
-	if posts != nil {
-		response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"posts": posts})
-	} else {
-		response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"posts": []post.Post{}})
+
+	ctx := r.Context()
+	results, err := handler.Service.Search(ctx, r.URL.Query().Get("q"), req)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
 	}
+
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{
+		"results":    results.Items,
+		"pagination": response.NewPagination(r, req, results),
+	})
 }
 
 func (handler *PostHandler) GetByIdHandler(w http.ResponseWriter, r *http.Request) {
@@ -74,34 +137,70 @@ func (handler *PostHandler) GetByIdHandler(w http.ResponseWriter, r *http.Reques
 	postId, err := strconv.Atoi(postIdStr)
 	if err != nil {
 		newError := errors.NewCustomError(
+			errors.Malformed,
 			"BAD_REQUEST",
 			fmt.Sprintf("Error parsing the path variable '%s' on the URL", postIdStr),
 			err.Error(),
 			time.Now())
-		response.CreateErrorResponse(w, r, http.StatusBadRequest, newError, r.URL.Path)
+		response.WriteError(w, r, newError)
 		return
 	}
 
 	ctx := r.Context()
 	post, error_get := handler.Service.GetPostById(ctx, uint(postId))
 	if error_get != nil {
-		response.CreateErrorResponse(w, r, http.StatusNotFound, error_get, r.URL.Path)
+		response.WriteError(w, r, error_get)
 		return
 	}
 
+	if notModified(w, r, post.Version) {
+		return
+	}
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"post": post})
+}
+
+// GetBySlugHandler returns the post with the slug. Former slugs of a post are permanently redirected
+// to its current one.
+func (handler *PostHandler) GetBySlugHandler(w http.ResponseWriter, r *http.Request, slug string) {
+	ctx := r.Context()
+	post, error_get := handler.Service.GetPostBySlug(ctx, slug)
+	if error_get != nil {
+		response.WriteError(w, r, error_get)
+		return
+	}
+
+	if post.Slug != slug {
+		http.Redirect(w, r, "/api/v1/posts/by-slug/"+url.PathEscape(post.Slug), http.StatusMovedPermanently)
+		return
+	}
+
+	if notModified(w, r, post.Version) {
+		return
+	}
 	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"post": post})
 }
 
 func (handler *PostHandler) CreateHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := authenticatedPrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	if !policy.CanCreatePost(principal) {
+		forbidden(w, r, "You are not allowed to create posts.")
+		return
+	}
+
 	var p post.Post
 	err := json.NewDecoder(r.Body).Decode(&p)
 	if err != nil {
 		newError := errors.NewCustomError(
+			errors.Malformed,
 			"BAD_REQUEST",
 			"The request is malformed.",
 			err.Error(),
 			time.Now())
-		response.CreateErrorResponse(w, r, http.StatusBadRequest, newError, r.URL.Path)
+		response.WriteError(w, r, newError)
 		return
 	}
 
@@ -110,7 +209,7 @@ func (handler *PostHandler) CreateHandler(w http.ResponseWriter, r *http.Request
 	ctx := r.Context()
 	error_creating := handler.Service.CreatePost(ctx, &p)
 	if error_creating != nil {
-		response.CreateErrorResponse(w, r, http.StatusBadRequest, error_creating, r.URL.Path)
+		response.WriteError(w, r, error_creating)
 		return
 	}
 
@@ -119,6 +218,10 @@ func (handler *PostHandler) CreateHandler(w http.ResponseWriter, r *http.Request
 }
 
 func (handler *PostHandler) UpdateHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authenticatedPrincipal(w, r); !ok {
+		return
+	}
+
 	// Getting the id from the request URL
 	var postIdStr = r.PathValue("id")
 
@@ -126,11 +229,17 @@ func (handler *PostHandler) UpdateHandler(w http.ResponseWriter, r *http.Request
 	var postId, err = strconv.Atoi(postIdStr)
 	if err != nil {
 		newError := errors.NewCustomError(
+			errors.Malformed,
 			"BAD_REQUEST",
 			fmt.Sprintf("Error parsing the path variable '%s' on the URL", postIdStr),
 			err.Error(),
 			time.Now())
-		response.CreateErrorResponse(w, r, http.StatusBadRequest, newError, r.URL.Path)
+		response.WriteError(w, r, newError)
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
 		return
 	}
 
@@ -139,27 +248,82 @@ func (handler *PostHandler) UpdateHandler(w http.ResponseWriter, r *http.Request
 	err = json.NewDecoder(r.Body).Decode(&p)
 	if err != nil {
 		newError := errors.NewCustomError(
+			errors.Malformed,
 			"BAD_REQUEST",
 			"The request is malformed.",
 			err.Error(),
 			time.Now())
-		response.CreateErrorResponse(w, r, http.StatusBadRequest, newError, r.URL.Path)
+		response.WriteError(w, r, newError)
 		return
 	}
 
 	defer r.Body.Close()
 
+	// The If-Match header takes precedence over the version sent in the body
+	if version != 0 {
+		p.Version = version
+	}
+
 	ctx := r.Context()
-	error_updating := handler.Service.UpdatePost(ctx, uint(postId), &p)
+	updated, error_updating := handler.Service.UpdatePost(ctx, uint(postId), &p)
 	if error_updating != nil {
-		response.CreateErrorResponse(w, r, http.StatusBadRequest, error_updating, r.URL.Path)
+		response.WriteError(w, r, error_updating)
 		return
 	}
 
-	response.EncodeDataToJSON(w, r, http.StatusOK, nil)
+	w.Header().Set("ETag", etag(updated.Version))
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"post": updated})
+}
+
+// PatchHandler changes only the fields of the content of the post present in the patch.
+func (handler *PostHandler) PatchHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authenticatedPrincipal(w, r); !ok {
+		return
+	}
+
+	postId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	existing, error_get := handler.Service.GetPostById(ctx, postId)
+	if error_get != nil {
+		response.WriteError(w, r, error_get)
+		return
+	}
+
+	var content post.Content
+	if !applyPatch(w, r, existing.Content(), &content) {
+		return
+	}
+
+	// The patch was applied to the version read, so the post is only changed while it is at that version
+	p := content.Post()
+	p.Version = existing.Version
+	if version != 0 {
+		p.Version = version
+	}
+	updated, error_updating := handler.Service.UpdatePost(ctx, postId, &p)
+	if error_updating != nil {
+		response.WriteError(w, r, error_updating)
+		return
+	}
+
+	w.Header().Set("ETag", etag(updated.Version))
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"post": updated})
 }
 
 func (handler *PostHandler) DeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authenticatedPrincipal(w, r); !ok {
+		return
+	}
+
 	// Getting the id from the request URL
 	var postIdStr = r.PathValue("id")
 
@@ -167,21 +331,96 @@ func (handler *PostHandler) DeleteHandler(w http.ResponseWriter, r *http.Request
 	var postId, err = strconv.Atoi(postIdStr)
 	if err != nil {
 		newError := errors.NewCustomError(
+			errors.Malformed,
 			"BAD_REQUEST",
 			fmt.Sprintf("Error parsing the path variable '%s' on the URL", postIdStr),
 			err.Error(),
 			time.Now())
-		response.CreateErrorResponse(w, r, http.StatusBadRequest, newError, r.URL.Path)
+		response.WriteError(w, r, newError)
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
 		return
 	}
 
 	// Deleting the post
 	ctx := r.Context()
-	error_deleting := handler.Service.DeletePost(ctx, uint(postId))
+	error_deleting := handler.Service.DeletePost(ctx, uint(postId), version)
 	if error_deleting != nil {
-		response.CreateErrorResponse(w, r, http.StatusBadRequest, error_deleting, r.URL.Path)
+		response.WriteError(w, r, error_deleting)
 		return
 	}
 
 	response.EncodeDataToJSON(w, r, http.StatusOK, nil)
 }
+
+// RestoreHandler takes the post out of the trash.
+func (handler *PostHandler) RestoreHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := authenticatedPrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	if !policy.CanManageTrash(principal) {
+		forbidden(w, r, "You are not allowed to restore posts.")
+		return
+	}
+
+	postId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	p, error_restoring := handler.Service.RestorePost(ctx, postId)
+	if error_restoring != nil {
+		response.WriteError(w, r, error_restoring)
+		return
+	}
+
+	w.Header().Set("ETag", etag(p.Version))
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"post": p})
+}
+
+func (handler *PostHandler) ChangeStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authenticatedPrincipal(w, r); !ok {
+		return
+	}
+
+	postId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	var change post.StatusChange
+	err := json.NewDecoder(r.Body).Decode(&change)
+	if err != nil {
+		newError := errors.NewCustomError(
+			errors.Malformed,
+			"BAD_REQUEST",
+			"The request is malformed.",
+			err.Error(),
+			time.Now())
+		response.WriteError(w, r, newError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	ctx := r.Context()
+	p, error_changing := handler.Service.ChangePostStatus(ctx, postId, version, change)
+	if error_changing != nil {
+		response.WriteError(w, r, error_changing)
+		return
+	}
+
+	w.Header().Set("ETag", etag(p.Version))
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"post": p})
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/post"
+	"github.com/cortzero/go-postgres-blog/internal/server/response"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+)
+
+// parsePostQuery reads the filters, the sort and the pagination of a listing of posts from the
+// query parameters. If any of them is invalid it writes a 400 response and returns false.
+//
+// Supported parameters: author (id or username), tag (slug), status, created_after, created_before,
+// title_contains and sort, a comma separated list of fields where a leading '-' means descending order.
+func parsePostQuery(w http.ResponseWriter, r *http.Request) (post.Query, bool) {
+	req, ok := parsePageRequest(w, r)
+	if !ok {
+		return post.Query{}, false
+	}
+
+	values := r.URL.Query()
+	query := post.Query{
+		TagSlug:       values.Get("tag"),
+		Status:        post.Status(values.Get("status")),
+		TitleContains: values.Get("title_contains"),
+		Page:          req,
+	}
+
+	if author := values.Get("author"); author != "" {
+		if id, err := strconv.ParseUint(author, 10, 64); err == nil {
+			authorId := uint(id)
+			query.AuthorID = &authorId
+		} else {
+			query.AuthorUsername = author
+		}
+	}
+
+	for _, param := range []struct {
+		name  string
+		value **time.Time
+	}{{"created_after", &query.CreatedAfter}, {"created_before", &query.CreatedBefore}} {
+		str := values.Get(param.name)
+		if str == "" {
+			continue
+		}
+		t, err := parseQueryTime(str)
+		if err != nil {
+			badPostQuery(w, r, fmt.Sprintf("The query parameter '%s' must be a date (2006-01-02) or a RFC 3339 timestamp.", param.name))
+			return post.Query{}, false
+		}
+		*param.value = &t
+	}
+
+	if sort := values.Get("sort"); sort != "" {
+		seen := make(map[string]bool)
+		for _, field := range strings.Split(sort, ",") {
+			sortField := post.SortField{Field: strings.TrimSpace(field)}
+			if strings.HasPrefix(sortField.Field, "-") {
+				sortField.Field = strings.TrimPrefix(sortField.Field, "-")
+				sortField.Desc = true
+			}
+			if !post.IsSortable(sortField.Field) || seen[sortField.Field] {
+				badPostQuery(w, r, fmt.Sprintf("Invalid sort field '%s'. The posts can be sorted by: %s.",
+					field, strings.Join(post.SortableFields, ", ")))
+				return post.Query{}, false
+			}
+			seen[sortField.Field] = true
+			query.Sort = append(query.Sort, sortField)
+		}
+	}
+
+	return query, true
+}
+
+func parseQueryTime(str string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", str); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, str)
+}
+
+func badPostQuery(w http.ResponseWriter, r *http.Request, details string) {
+	newError := errors.NewCustomError(
+		errors.Malformed,
+		"BAD_REQUEST",
+		"The query parameters are not valid.",
+		details,
+		time.Now())
+	response.WriteError(w, r, newError)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/server/response"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+)
+
+func (handler *PostHandler) GetRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authenticatedPrincipal(w, r); !ok {
+		return
+	}
+
+	postId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	revisions, err := handler.Service.GetPostRevisions(ctx, postId)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"revisions": revisions})
+}
+
+// DiffRevisionsHandler compares the revisions given by the 'from' and 'to' query parameters.
+func (handler *PostHandler) DiffRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authenticatedPrincipal(w, r); !ok {
+		return
+	}
+
+	postId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var revisions [2]int
+	for i, name := range []string{"from", "to"} {
+		value, err := strconv.Atoi(r.URL.Query().Get(name))
+		if err != nil || value < 1 {
+			newError := errors.NewCustomError(
+				errors.Malformed,
+				"BAD_REQUEST",
+				"The query parameters are not valid.",
+				fmt.Sprintf("The query parameter '%s' must be the number of a revision.", name),
+				time.Now())
+			response.WriteError(w, r, newError)
+			return
+		}
+		revisions[i] = value
+	}
+
+	ctx := r.Context()
+	revisionDiff, err := handler.Service.DiffPostRevisions(ctx, postId, revisions[0], revisions[1])
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"diff": revisionDiff})
+}
+
+func (handler *PostHandler) RestoreRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authenticatedPrincipal(w, r); !ok {
+		return
+	}
+
+	postId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return
+	}
+	revision, ok := parseIdPathValue(w, r, "rev")
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	p, err := handler.Service.RestorePostRevision(ctx, postId, int(revision))
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"post": p})
+}
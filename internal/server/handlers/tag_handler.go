@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/post"
+	"github.com/cortzero/go-postgres-blog/internal/model/tag"
+	"github.com/cortzero/go-postgres-blog/internal/server/response"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+)
+
+var (
+	tagsUrlRegExpNoVars = regexp.MustCompile(`^/api/v1/tags$`)
+	tagsUrlRegExpPosts  = regexp.MustCompile(`^/api/v1/tags/([a-z0-9\p{L}-]+)/posts$`)
+)
+
+type TagHandler struct {
+	Service     tag.Service
+	PostService post.Service
+}
+
+func NewTagHandler(service tag.Service, postService post.Service) *TagHandler {
+	return &TagHandler{
+		Service:     service,
+		PostService: postService,
+	}
+}
+
+func (handler *TagHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reqURL := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case r.Method == http.MethodGet && tagsUrlRegExpNoVars.MatchString(reqURL):
+		handler.GetAllHandler(w, r)
+		return
+	case r.Method == http.MethodGet && tagsUrlRegExpPosts.MatchString(reqURL):
+		handler.GetPostsHandler(w, r)
+		return
+	default:
+		newError := errors.NewCustomError(
+			errors.NotFound,
+			"NOT_FOUND",
+			"Could not found the requested URL.",
+			fmt.Sprintf("The URL '%s' does not exist.", r.URL.Path),
+			time.Now())
+		response.WriteError(w, r, newError)
+		return
+	}
+}
+
+func (handler *TagHandler) GetAllHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	tags, err := handler.Service.GetAllTags(ctx)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	if tags != nil {
+		response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"tags": tags})
+	} else {
+		response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"tags": []tag.Tag{}})
+	}
+}
+
+func (handler *TagHandler) GetPostsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	t, err_tag := handler.Service.GetTagBySlug(ctx, r.PathValue("slug"))
+	if err_tag != nil {
+		response.WriteError(w, r, err_tag)
+		return
+	}
+
+	query, ok := parsePostQuery(w, r)
+	if !ok {
+		return
+	}
+	query.TagSlug = t.Slug
+
+	posts, err := handler.PostService.GetAllPosts(ctx, query)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{
+		"tag":        t,
+		"posts":      posts.Items,
+		"pagination": response.NewPagination(r, query.Page, posts),
+	})
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/trash"
+	"github.com/cortzero/go-postgres-blog/internal/server/response"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+	"github.com/cortzero/go-postgres-blog/internal/service/policy"
+)
+
+var trashUrlRegExpNoVars = regexp.MustCompile(`^/api/v1/trash$`)
+
+// TrashHandler lists the deleted posts and users. They are restored through their own endpoints.
+type TrashHandler struct {
+	Service trash.Service
+}
+
+func NewTrashHandler(service trash.Service) *TrashHandler {
+	return &TrashHandler{
+		Service: service,
+	}
+}
+
+func (handler *TrashHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reqURL := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case r.Method == http.MethodGet && trashUrlRegExpNoVars.MatchString(reqURL):
+		handler.GetAllHandler(w, r)
+		return
+	default:
+		newError := errors.NewCustomError(
+			errors.NotFound,
+			"NOT_FOUND",
+			"Could not found the requested URL.",
+			fmt.Sprintf("The URL '%s' does not exist.", r.URL.Path),
+			time.Now())
+		response.WriteError(w, r, newError)
+		return
+	}
+}
+
+func (handler *TrashHandler) GetAllHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := authenticatedPrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	if !policy.CanManageTrash(principal) {
+		forbidden(w, r, "You are not allowed to see the trash.")
+		return
+	}
+
+	req, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	items, err := handler.Service.GetTrash(ctx, req)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{
+		"items":      items.Items,
+		"pagination": response.NewPagination(r, req, items),
+	})
+}
@@ -9,23 +9,31 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cortzero/go-postgres-blog/internal/model/post"
 	"github.com/cortzero/go-postgres-blog/internal/model/user"
 	"github.com/cortzero/go-postgres-blog/internal/server/response"
 	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+	"github.com/cortzero/go-postgres-blog/internal/service/policy"
 )
 
 var (
 	usersUrlRegExpNoVars = regexp.MustCompile(`^/api/v1/users$`)
 	usersUrlRegExpVars   = regexp.MustCompile(`^/api/v1/users/(\d+)$`)
+	usersUrlRegExpRole   = regexp.MustCompile(`^/api/v1/users/(\d+)/role$`)
+	usersUrlRegExpName   = regexp.MustCompile(`^/api/v1/users/by-username/([^/]+)$`)
+	usersUrlRegExpPosts  = regexp.MustCompile(`^/api/v1/users/(\d+)/posts$`)
+	usersUrlRegExpTrash  = regexp.MustCompile(`^/api/v1/users/(\d+)/restore$`)
 )
 
 type UserHandler struct {
-	Service user.Service
+	Service     user.Service
+	PostService post.Service
 }
 
-func NewUserHandler(service user.Service) *UserHandler {
+func NewUserHandler(service user.Service, postService post.Service) *UserHandler {
 	return &UserHandler{
-		Service: service,
+		Service:     service,
+		PostService: postService,
 	}
 }
 
@@ -38,22 +46,38 @@ func (handler *UserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case r.Method == http.MethodGet && usersUrlRegExpVars.Match([]byte(reqURL)):
 		handler.GetByIdHandler(w, r)
 		return
+	case r.Method == http.MethodGet && usersUrlRegExpPosts.Match([]byte(reqURL)):
+		handler.GetPostsHandler(w, r)
+		return
+	case r.Method == http.MethodGet && usersUrlRegExpName.Match([]byte(reqURL)):
+		handler.GetByUsernameHandler(w, r, usersUrlRegExpName.FindStringSubmatch(reqURL)[1])
+		return
 	case r.Method == http.MethodPost && usersUrlRegExpNoVars.Match([]byte(reqURL)):
 		handler.CreateHandler(w, r)
 		return
 	case r.Method == http.MethodPut && usersUrlRegExpVars.Match([]byte(reqURL)):
 		handler.UpdateHandler(w, r)
 		return
+	case r.Method == http.MethodPatch && usersUrlRegExpVars.Match([]byte(reqURL)):
+		handler.PatchHandler(w, r)
+		return
 	case r.Method == http.MethodDelete && usersUrlRegExpVars.Match([]byte(reqURL)):
 		handler.DeleteHandler(w, r)
 		return
+	case r.Method == http.MethodPut && usersUrlRegExpRole.Match([]byte(reqURL)):
+		handler.UpdateRoleHandler(w, r)
+		return
+	case r.Method == http.MethodPost && usersUrlRegExpTrash.Match([]byte(reqURL)):
+		handler.RestoreHandler(w, r)
+		return
 	default:
 		newError := errors.NewCustomError(
+			errors.NotFound,
 			"NOT_FOUND",
 			"Could not found the requested URL.",
 			fmt.Sprintf("The URL '%s' does not exist.", r.URL.Path),
 			time.Now())
-		response.CreateErrorResponse(w, r, http.StatusNotFound, newError, r.URL.Path)
+		response.WriteError(w, r, newError)
 		return
 	}
 }
@@ -63,11 +87,12 @@ func (handler *UserHandler) CreateHandler(w http.ResponseWriter, r *http.Request
 	err := json.NewDecoder(r.Body).Decode(&u)
 	if err != nil {
 		newError := errors.NewCustomError(
+			errors.Malformed,
 			"BAD_REQUEST",
 			"The request is malformed.",
 			"The body of the request may have an incorrect format.",
 			time.Now())
-		response.CreateErrorResponse(w, r, http.StatusBadRequest, newError, r.URL.Path)
+		response.WriteError(w, r, newError)
 		return
 	}
 
@@ -76,7 +101,7 @@ func (handler *UserHandler) CreateHandler(w http.ResponseWriter, r *http.Request
 	ctx := r.Context()
 	err_creation := handler.Service.CreateUser(ctx, &u)
 	if err_creation != nil {
-		response.CreateErrorResponse(w, r, http.StatusBadRequest, err_creation, r.URL.Path)
+		response.WriteError(w, r, err_creation)
 		return
 	}
 
@@ -86,87 +111,335 @@ func (handler *UserHandler) CreateHandler(w http.ResponseWriter, r *http.Request
 }
 
 func (handler *UserHandler) GetAllHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	req, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
 
-	users, err := handler.Service.GetAllUsers(ctx)
+	ctx := r.Context()
+	users, err := handler.Service.GetAllUsers(ctx, req)
 	if err != nil {
-		response.CreateErrorResponse(w, r, http.StatusBadRequest, err, r.URL.Path)
+		response.WriteError(w, r, err)
 		return
 	}
-	if users != nil {
-		response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"users": users})
-	} else {
-		response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"users": []user.User{}})
-	}
+
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{
+		"users":      users.Items,
+		"pagination": response.NewPagination(r, req, users),
+	})
 }
 
 func (handler *UserHandler) GetByIdHandler(w http.ResponseWriter, r *http.Request) {
-	userIdStr := r.PathValue("id")
+	userId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return
+	}
 
-	userId, err := strconv.Atoi(userIdStr)
-	if err != nil {
-		response.CreateErrorResponse(w, r, http.StatusBadRequest, err.Error(), r.URL.Path)
+	ctx := r.Context()
+	user, error_get := handler.Service.GetUserById(ctx, userId)
+	if error_get != nil {
+		response.WriteError(w, r, error_get)
+		return
+	}
+
+	if notModified(w, r, user.Version) {
 		return
 	}
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"user": user})
+}
 
+func (handler *UserHandler) GetByUsernameHandler(w http.ResponseWriter, r *http.Request, username string) {
 	ctx := r.Context()
-	user, error_get := handler.Service.GetUserById(ctx, uint(userId))
+	user, error_get := handler.Service.GetUserByUsername(ctx, username)
 	if error_get != nil {
-		response.CreateErrorResponse(w, r, http.StatusNotFound, error_get, r.URL.Path)
+		response.WriteError(w, r, error_get)
 		return
 	}
 
+	if notModified(w, r, user.Version) {
+		return
+	}
 	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"user": user})
 }
 
-func (handler *UserHandler) UpdateHandler(w http.ResponseWriter, r *http.Request) {
-	userIdStr := r.PathValue("id")
+// GetPostsHandler lists the posts of the user, answering 404 when the user does not exist.
+func (handler *UserHandler) GetPostsHandler(w http.ResponseWriter, r *http.Request) {
+	userId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return
+	}
+	req, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	if _, error_get := handler.Service.GetUserById(ctx, userId); error_get != nil {
+		response.WriteError(w, r, error_get)
+		return
+	}
 
-	userId, err := strconv.Atoi(userIdStr)
+	posts, err := handler.PostService.GetPostsByUserId(ctx, userId, req)
 	if err != nil {
-		response.CreateErrorResponse(w, r, http.StatusBadRequest, err.Error(), r.URL.Path)
+		response.WriteError(w, r, err)
+		return
+	}
+
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{
+		"posts":      posts.Items,
+		"pagination": response.NewPagination(r, req, posts),
+	})
+}
+
+func (handler *UserHandler) UpdateHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := authenticatedPrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	userId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if !policy.CanManageUser(principal, userId) {
+		forbidden(w, r, "You are not allowed to update this user.")
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
 		return
 	}
 
 	var u user.User
-	err = json.NewDecoder(r.Body).Decode(&u)
+	err := json.NewDecoder(r.Body).Decode(&u)
 	if err != nil {
 		newError := errors.NewCustomError(
+			errors.Malformed,
 			"BAD_REQUEST",
 			"The request is malformed.",
 			"The body of the request may have an incorrect format.",
 			time.Now())
-		response.CreateErrorResponse(w, r, http.StatusBadRequest, newError, r.URL.Path)
+		response.WriteError(w, r, newError)
 		return
 	}
 
 	defer r.Body.Close()
 
+	// The If-Match header takes precedence over the version sent in the body
+	if version != 0 {
+		u.Version = version
+	}
+
 	ctx := r.Context()
-	error_update := handler.Service.UpdateUser(ctx, uint(userId), &u)
+	updated, error_update := handler.Service.UpdateUser(ctx, userId, &u)
+	if error_update != nil {
+		response.WriteError(w, r, error_update)
+		return
+	}
+
+	w.Header().Set("ETag", etag(updated.Version))
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"user": updated})
+}
+
+// PatchHandler changes only the fields of the profile of the user present in the patch.
+func (handler *UserHandler) PatchHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := authenticatedPrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	userId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if !policy.CanManageUser(principal, userId) {
+		forbidden(w, r, "You are not allowed to update this user.")
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	existing, error_get := handler.Service.GetUserById(ctx, userId)
+	if error_get != nil {
+		response.WriteError(w, r, error_get)
+		return
+	}
+
+	var profile user.Profile
+	if !applyPatch(w, r, existing.Profile(), &profile) {
+		return
+	}
+
+	// The patch was applied to the version read, so the user is only changed while it is at that version
+	u := profile.User()
+	u.Version = existing.Version
+	if version != 0 {
+		u.Version = version
+	}
+	updated, error_update := handler.Service.PatchUser(ctx, userId, &u)
 	if error_update != nil {
-		response.CreateErrorResponse(w, r, http.StatusNotFound, error_update, r.URL.Path)
+		response.WriteError(w, r, error_update)
 		return
 	}
 
-	response.EncodeDataToJSON(w, r, http.StatusOK, nil)
+	w.Header().Set("ETag", etag(updated.Version))
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"user": updated})
 }
 
+// DeleteHandler deletes the user with the policy given by the required 'policy' query parameter:
+// 'cascade' moves their posts to the trash, 'reassign' gives them to the user given by 'reassign_to' and
+// 'anonymize' gives them and their comments to the ghost account. With 'dry_run=true' it only reports
+// what would be affected.
 func (handler *UserHandler) DeleteHandler(w http.ResponseWriter, r *http.Request) {
-	userIdStr := r.PathValue("id")
+	principal, ok := authenticatedPrincipal(w, r)
+	if !ok {
+		return
+	}
 
-	userId, err := strconv.Atoi(userIdStr)
-	if err != nil {
-		response.CreateErrorResponse(w, r, http.StatusBadRequest, err.Error(), r.URL.Path)
+	userId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if !policy.CanManageUser(principal, userId) {
+		forbidden(w, r, "You are not allowed to delete this user.")
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	deletion, ok := parseDeletion(w, r)
+	if !ok {
 		return
 	}
 
 	ctx := r.Context()
-	error_deleting := handler.Service.DeleteUser(ctx, uint(userId))
+	report, error_deleting := handler.Service.DeleteUser(ctx, userId, version, deletion)
 	if error_deleting != nil {
-		response.CreateErrorResponse(w, r, http.StatusNotFound, error_deleting, r.URL.Path)
+		response.WriteError(w, r, error_deleting)
+		return
+	}
+
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"deletion": report})
+}
+
+// parseDeletion reads the policy of the deletion of a user from the query parameters. If any of them
+// is invalid it writes a 400 response and returns false.
+func parseDeletion(w http.ResponseWriter, r *http.Request) (user.Deletion, bool) {
+	values := r.URL.Query()
+	deletion := user.Deletion{Policy: user.DeletionPolicy(values.Get("policy"))}
+
+	if reassignTo := values.Get("reassign_to"); reassignTo != "" {
+		id, err := strconv.ParseUint(reassignTo, 10, 64)
+		if err != nil || id == 0 {
+			badDeletionQuery(w, r, "The query parameter 'reassign_to' must be the id of a user.")
+			return user.Deletion{}, false
+		}
+		deletion.ReassignTo = uint(id)
+	}
+
+	if dryRun := values.Get("dry_run"); dryRun != "" {
+		value, err := strconv.ParseBool(dryRun)
+		if err != nil {
+			badDeletionQuery(w, r, "The query parameter 'dry_run' must be 'true' or 'false'.")
+			return user.Deletion{}, false
+		}
+		deletion.DryRun = value
+	}
+	return deletion, true
+}
+
+func badDeletionQuery(w http.ResponseWriter, r *http.Request, details string) {
+	newError := errors.NewCustomError(
+		errors.Malformed,
+		"BAD_REQUEST",
+		"The query parameters are not valid.",
+		details,
+		time.Now())
+	response.WriteError(w, r, newError)
+}
+
+// RestoreHandler takes the user out of the trash along with the posts that were deleted with them.
+func (handler *UserHandler) RestoreHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := authenticatedPrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	if !policy.CanManageTrash(principal) {
+		forbidden(w, r, "You are not allowed to restore users.")
+		return
+	}
+
+	userId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	u, error_restoring := handler.Service.RestoreUser(ctx, userId)
+	if error_restoring != nil {
+		response.WriteError(w, r, error_restoring)
+		return
+	}
+
+	w.Header().Set("ETag", etag(u.Version))
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"user": u})
+}
+
+func (handler *UserHandler) UpdateRoleHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := authenticatedPrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	if !policy.CanChangeRoles(principal) {
+		forbidden(w, r, "You are not allowed to change the role of a user.")
+		return
+	}
+
+	userId, ok := parseIdPathValue(w, r, "id")
+	if !ok {
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	var body user.RoleUpdate
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		newError := errors.NewCustomError(
+			errors.Malformed,
+			"BAD_REQUEST",
+			"The request is malformed.",
+			"The body of the request may have an incorrect format.",
+			time.Now())
+		response.WriteError(w, r, newError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	ctx := r.Context()
+	u, error_update := handler.Service.UpdateUserRole(ctx, userId, version, body.Role)
+	if error_update != nil {
+		response.WriteError(w, r, error_update)
 		return
 	}
 
-	response.EncodeDataToJSON(w, r, http.StatusOK, nil)
+	w.Header().Set("ETag", etag(u.Version))
+	response.EncodeDataToJSON(w, r, http.StatusOK, response.Map{"user": u})
 }
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/auth"
+	"github.com/cortzero/go-postgres-blog/internal/server/response"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+)
+
+// Authentication verifies the bearer token sent in the 'Authorization' header and stores the
+// authenticated user in the request context. Requests without the header are passed through anonymously,
+// so handlers decide which operations require a logged in user.
+func Authentication(service auth.Service, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme, accessToken, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || accessToken == "" {
+			newError := errors.NewCustomError(
+				errors.Unauthorized,
+				"INVALID_TOKEN",
+				"The 'Authorization' header is malformed.",
+				"The header must have the format 'Bearer <token>'.",
+				time.Now())
+			unauthorized(w, r, newError)
+			return
+		}
+
+		principal, err := service.Authenticate(r.Context(), accessToken)
+		if err != nil {
+			unauthorized(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, err *errors.CustomError) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+	response.WriteError(w, r, err)
+}
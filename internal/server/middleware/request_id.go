@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"github.com/cortzero/go-postgres-blog/internal/server/response"
+)
+
+// RequestIDHeader carries the id of the request, both in the request and in the response
+const RequestIDHeader = "X-Request-ID"
+
+// requestIdRegExp limits the ids sent by clients or proxies to a length and characters safe to log
+var requestIdRegExp = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestID gives every request an id, stored in the request context and sent back in the 'X-Request-ID'
+// header so that errors reported by clients can be traced. An id already set by a proxy is kept.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(RequestIDHeader)
+		if !requestIdRegExp.MatchString(requestId) {
+			requestId = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, requestId)
+		next.ServeHTTP(w, r.WithContext(response.WithRequestID(r.Context(), requestId)))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	// crypto/rand never fails on the supported platforms
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,57 @@
+package response
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/page"
+)
+
+// Pagination is sent along with the items of the list endpoints
+type Pagination struct {
+	Total  int    `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset *int   `json:"offset,omitempty"`
+	Next   string `json:"next,omitempty"`
+	Prev   string `json:"prev,omitempty"`
+}
+
+// NewPagination builds the pagination of a page, linking to the surrounding pages with the same
+// style the client used: offset links for offset requests and cursor links for cursor requests.
+func NewPagination[T any](r *http.Request, req page.Request, p page.Page[T]) Pagination {
+	pagination := Pagination{
+		Total: p.Total,
+		Limit: req.Limit,
+	}
+
+	if req.Cursor != nil {
+		if p.HasNext && p.NextCursor != nil {
+			pagination.Next = pageLink(r.URL, req.Limit, "cursor", p.NextCursor.Encode())
+		}
+		if p.HasPrev && p.PrevCursor != nil {
+			pagination.Prev = pageLink(r.URL, req.Limit, "cursor", p.PrevCursor.Encode())
+		}
+		return pagination
+	}
+
+	offset := req.Offset
+	pagination.Offset = &offset
+	if p.HasNext {
+		pagination.Next = pageLink(r.URL, req.Limit, "offset", strconv.Itoa(req.Offset+req.Limit))
+	}
+	if p.HasPrev {
+		pagination.Prev = pageLink(r.URL, req.Limit, "offset", strconv.Itoa(max(req.Offset-req.Limit, 0)))
+	}
+	return pagination
+}
+
+// pageLink returns the URL of the request with the pagination parameters replaced, keeping any other parameter.
+func pageLink(u *url.URL, limit int, key string, value string) string {
+	query := u.Query()
+	query.Del("offset")
+	query.Del("cursor")
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set(key, value)
+	return u.Path + "?" + query.Encode()
+}
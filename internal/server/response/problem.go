@@ -0,0 +1,111 @@
+package response
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+)
+
+// ProblemMediaType is the media type of the error responses, defined by RFC 9457
+const ProblemMediaType = "application/problem+json"
+
+// LegacyErrorMediaType is sent in the 'Accept' header by the clients that still expect the errors in the
+// format of ErrorResponse. It is deprecated and will be removed once the clients have migrated.
+const LegacyErrorMediaType = "application/vnd.blog.legacy-error+json"
+
+// problemTypeBase is the path under which the types of problems are identified, the error type of the
+// CustomError in kebab case being the last segment
+const problemTypeBase = "/problems/"
+
+// Problem holds the details of an error as defined by RFC 9457. The extension members are serialized
+// along with the standard ones.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]any
+}
+
+// NewProblem describes the error of the request. Besides the standard members it has the kind and the
+// error type of the CustomError, the time it happened, the id of the request and the invalid fields if any.
+func NewProblem(r *http.Request, status int, err *errors.CustomError) Problem {
+	problem := Problem{
+		Type:     problemTypeBase + strings.ToLower(strings.ReplaceAll(err.ErrorType, "_", "-")),
+		Title:    err.Message,
+		Status:   status,
+		Detail:   err.Details,
+		Instance: r.URL.Path,
+		Extensions: map[string]any{
+			"kind":      err.Kind,
+			"code":      err.ErrorType,
+			"timestamp": err.Timestamp.UTC().Format(time.RFC3339),
+		},
+	}
+	if requestId := RequestIDFromContext(r.Context()); requestId != "" {
+		problem.Extensions["request_id"] = requestId
+	}
+	if len(err.Fields) > 0 {
+		problem.Extensions["errors"] = err.Fields
+	}
+	return problem
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]any, len(p.Extensions)+5)
+	for name, value := range p.Extensions {
+		members[name] = value
+	}
+	members["type"] = p.Type
+	members["title"] = p.Title
+	members["status"] = p.Status
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+	return json.Marshal(members)
+}
+
+// WriteError writes the error with the status that corresponds to its kind, as problem details unless
+// the client asks for the legacy format. Responses for a missing or invalid authentication carry the
+// 'WWW-Authenticate' header.
+func WriteError(w http.ResponseWriter, r *http.Request, err *errors.CustomError) error {
+	status := StatusFor(err)
+	if err.Kind == errors.Unauthorized && w.Header().Get("WWW-Authenticate") == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	}
+
+	if acceptsLegacyErrors(r) {
+		w.Header().Set("Deprecation", "true")
+		return CreateErrorResponse(w, r, status, err, r.URL.Path)
+	}
+
+	body, err_marshal := json.Marshal(NewProblem(r, status, err))
+	if err_marshal != nil {
+		return err_marshal
+	}
+	w.Header().Set("Content-Type", ProblemMediaType)
+	w.WriteHeader(status)
+	_, err_write := w.Write(body)
+	return err_write
+}
+
+// acceptsLegacyErrors reports whether the 'Accept' header of the request lists LegacyErrorMediaType.
+func acceptsLegacyErrors(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+			if err == nil && mediaType == LegacyErrorMediaType {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,16 @@
+package response
+
+import "context"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of the context that carries the id of the request.
+func WithRequestID(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestId)
+}
+
+// RequestIDFromContext returns the id of the request, or an empty string if it has none.
+func RequestIDFromContext(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIDKey{}).(string)
+	return requestId
+}
@@ -4,8 +4,14 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
 )
 
+// ErrorResponse is the former format of the error responses, only sent to the clients that ask for
+// LegacyErrorMediaType.
+//
+// Deprecated: errors are sent as problem details, see Problem.
 type ErrorResponse struct {
 	Status     string `json:"status"`
 	StatusCode string `json:"status_code"`
@@ -33,6 +39,9 @@ func EncodeDataToJSON(w http.ResponseWriter, r *http.Request, statuscode int, da
 	return nil
 }
 
+// CreateErrorResponse writes the error in the former format.
+//
+// Deprecated: use WriteError, which negotiates the format of the response.
 func CreateErrorResponse(w http.ResponseWriter, r *http.Request, statuscode int, errorObj any, path string) error {
 	resp := ErrorResponse{
 		Status:     "Failed",
@@ -42,3 +51,27 @@ func CreateErrorResponse(w http.ResponseWriter, r *http.Request, statuscode int,
 	}
 	return EncodeDataToJSON(w, r, statuscode, resp)
 }
+
+// StatusFor returns the HTTP status of the responses for errors of the kind of err.
+func StatusFor(err *errors.CustomError) int {
+	switch err.Kind {
+	case errors.NotFound:
+		return http.StatusNotFound
+	case errors.Conflict:
+		return http.StatusConflict
+	case errors.Validation:
+		return http.StatusUnprocessableEntity
+	case errors.Malformed:
+		return http.StatusBadRequest
+	case errors.Unsupported:
+		return http.StatusUnsupportedMediaType
+	case errors.Unauthorized:
+		return http.StatusUnauthorized
+	case errors.Forbidden:
+		return http.StatusForbidden
+	case errors.PreconditionFailed:
+		return http.StatusPreconditionFailed
+	default:
+		return http.StatusInternalServerError
+	}
+}
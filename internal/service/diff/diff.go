@@ -0,0 +1,123 @@
+package diff
+
+import "strings"
+
+// Op is the kind of change of a line
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// Line is a line that is kept, inserted or deleted going from one text to another
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines returns the shortest line-level edit script that turns the text from into the text to,
+// using the linear space variant of the Myers difference algorithm.
+func Lines(from string, to string) []Line {
+	d := differ{a: splitLines(from), b: splitLines(to), lines: []Line{}}
+	d.compare(0, len(d.a), 0, len(d.b))
+	return d.lines
+}
+
+type differ struct {
+	a, b  []string
+	lines []Line
+}
+
+// compare appends the edit script of a[aLo:aHi] and b[bLo:bHi], splitting them at a point of a shortest
+// path until what is left is only kept, inserted or deleted lines.
+func (d *differ) compare(aLo int, aHi int, bLo int, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.lines = append(d.lines, Line{Op: Equal, Text: d.a[aLo]})
+		aLo++
+		bLo++
+	}
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && d.a[aHi-1-suffix] == d.b[bHi-1-suffix] {
+		suffix++
+	}
+	aHi -= suffix
+	bHi -= suffix
+
+	switch {
+	case aLo == aHi:
+		for _, text := range d.b[bLo:bHi] {
+			d.lines = append(d.lines, Line{Op: Insert, Text: text})
+		}
+	case bLo == bHi:
+		for _, text := range d.a[aLo:aHi] {
+			d.lines = append(d.lines, Line{Op: Delete, Text: text})
+		}
+	default:
+		x, y := d.split(aLo, aHi, bLo, bHi)
+		d.compare(aLo, x, bLo, y)
+		d.compare(x, aHi, y, bHi)
+	}
+
+	for _, text := range d.a[aHi : aHi+suffix] {
+		d.lines = append(d.lines, Line{Op: Equal, Text: text})
+	}
+}
+
+// split searches a shortest path from both ends at once and returns the point where the searches meet.
+// The ranges have no common first or last line, so the point is never one of their ends.
+func (d *differ) split(aLo int, aHi int, bLo int, bHi int) (int, int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	limit := (n + m + 1) / 2
+
+	// forward[k] is the furthest index reached on a along the diagonal k from the start, and backward[k]
+	// the furthest one reached from the end, counted backwards on the diagonal delta-k.
+	offset := limit + 1
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	for steps := 0; steps <= limit; steps++ {
+		for k := -steps; k <= steps; k += 2 {
+			var x int
+			if k == -steps || (k != steps && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			startX, startY := x, x-k
+			for y := x - k; x < n && y < m && d.a[aLo+x] == d.b[bLo+y]; y++ {
+				x++
+			}
+			forward[offset+k] = x
+			if r := delta - k; delta%2 != 0 && r >= -(steps-1) && r <= steps-1 && x+backward[offset+r] >= n {
+				return aLo + startX, bLo + startY
+			}
+		}
+		for r := -steps; r <= steps; r += 2 {
+			var x int
+			if r == -steps || (r != steps && backward[offset+r-1] < backward[offset+r+1]) {
+				x = backward[offset+r+1]
+			} else {
+				x = backward[offset+r-1] + 1
+			}
+			startX, startY := x, x-r
+			for y := x - r; x < n && y < m && d.a[aHi-1-x] == d.b[bHi-1-y]; y++ {
+				x++
+			}
+			backward[offset+r] = x
+			if k := delta - r; delta%2 == 0 && k >= -steps && k <= steps && x+forward[offset+k] >= n {
+				return aHi - startX, bHi - startY
+			}
+		}
+	}
+	// Unreachable: the searches meet after at most n+m steps in total
+	return aLo, bLo
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
@@ -0,0 +1,76 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	eq := func(text string) Line { return Line{Op: Equal, Text: text} }
+	ins := func(text string) Line { return Line{Op: Insert, Text: text} }
+	del := func(text string) Line { return Line{Op: Delete, Text: text} }
+
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want []Line
+	}{
+		{"both empty", "", "", []Line{}},
+		{"from empty", "", "a\nb", []Line{ins("a"), ins("b")}},
+		{"to empty", "a\nb", "", []Line{del("a"), del("b")}},
+		{"same", "a\nb", "a\nb", []Line{eq("a"), eq("b")}},
+		{"crlf", "a\r\nb", "a\nb", []Line{eq("a"), eq("b")}},
+		{"append", "a", "a\nb", []Line{eq("a"), ins("b")}},
+		{"prepend", "b", "a\nb", []Line{ins("a"), eq("b")}},
+		{"remove middle", "a\nb\nc", "a\nc", []Line{eq("a"), del("b"), eq("c")}},
+		{"replace", "a\nb\nc", "a\nx\nc", []Line{eq("a"), del("b"), ins("x"), eq("c")}},
+		{"all different", "a\nb", "c\nd", []Line{del("a"), del("b"), ins("c"), ins("d")}},
+		{"trailing newline", "a", "a\n", []Line{eq("a"), ins("")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Lines(tt.from, tt.to); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lines(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinesIsShortest(t *testing.T) {
+	tests := []struct {
+		from  string
+		to    string
+		edits int
+	}{
+		// The example of the paper of Myers
+		{"a\nb\nc\na\nb\nb\na", "c\nb\na\nb\na\nc", 5},
+		{"a\nb\nc\nd\ne", "e\nd\nc\nb\na", 8},
+		{"x\na\nb\nx\nc", "a\nx\nb\nc\nx", 4},
+	}
+
+	for _, tt := range tests {
+		lines := Lines(tt.from, tt.to)
+		var from, to []string
+		edits := 0
+		for _, l := range lines {
+			if l.Op != Insert {
+				from = append(from, l.Text)
+			}
+			if l.Op != Delete {
+				to = append(to, l.Text)
+			}
+			if l.Op != Equal {
+				edits++
+			}
+		}
+		if strings.Join(from, "\n") != tt.from || strings.Join(to, "\n") != tt.to {
+			t.Errorf("Lines(%q, %q) = %v, which does not turn one into the other", tt.from, tt.to, lines)
+		}
+		if edits != tt.edits {
+			t.Errorf("Lines(%q, %q) has %d edits, want %d", tt.from, tt.to, edits, tt.edits)
+		}
+	}
+}
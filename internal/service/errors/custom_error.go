@@ -2,15 +2,43 @@ package errors
 
 import "time"
 
+// Kind classifies an error so that clients can branch on it without knowing every error type. It is
+// also what decides the HTTP status of the response. Validation is for requests that can be read but
+// whose values are not valid, and Malformed for requests that can't be read, like a body that is not JSON.
+// Unsupported is for bodies in a media type the endpoint does not accept, and PreconditionFailed for
+// conditional requests whose If-Match does not match the current version of the resource.
+type Kind string
+
+const (
+	NotFound           Kind = "NOT_FOUND"
+	Conflict           Kind = "CONFLICT"
+	Validation         Kind = "VALIDATION"
+	Malformed          Kind = "MALFORMED"
+	Unsupported        Kind = "UNSUPPORTED_MEDIA_TYPE"
+	Unauthorized       Kind = "UNAUTHORIZED"
+	Forbidden          Kind = "FORBIDDEN"
+	PreconditionFailed Kind = "PRECONDITION_FAILED"
+	Internal           Kind = "INTERNAL"
+)
+
+// FieldError describes why the value of a field of the request is not valid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
 type CustomError struct {
-	ErrorType string    `json:"type"`
-	Message   string    `json:"message"`
-	Details   string    `json:"details"`
-	Timestamp time.Time `json:"timestamp"`
+	Kind      Kind         `json:"kind"`
+	ErrorType string       `json:"type"`
+	Message   string       `json:"message"`
+	Details   string       `json:"details"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	Timestamp time.Time    `json:"timestamp"`
 }
 
-func NewCustomError(errorType string, message string, details string, timestamp time.Time) *CustomError {
+func NewCustomError(kind Kind, errorType string, message string, details string, timestamp time.Time) *CustomError {
 	return &CustomError{
+		Kind:      kind,
 		ErrorType: errorType,
 		Message:   message,
 		Details:   details,
@@ -0,0 +1,53 @@
+package errors
+
+import "errors"
+
+// ErrNotFound is wrapped by the repositories when the row they look for does not exist.
+var ErrNotFound = errors.New("the resource does not exist")
+
+// ErrConflict is wrapped by the repositories when a change violates a unique constraint.
+var ErrConflict = errors.New("the resource already exists")
+
+// ErrVersionMismatch is wrapped by the repositories when a change expects a version of the row that is
+// not its current one, because another request changed it since it was read.
+var ErrVersionMismatch = errors.New("the resource was changed by another request")
+
+// ConflictError is returned by the repositories when a change violates the unique constraint.
+type ConflictError struct {
+	Constraint string
+	Err        error
+}
+
+func (e *ConflictError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConflictError) Unwrap() []error {
+	return []error{ErrConflict, e.Err}
+}
+
+// Is reports whether the error returned by a repository wraps target.
+func Is(err error, target error) bool {
+	return errors.Is(err, target)
+}
+
+// As finds the first error in the chain of err that matches target, like the As of the standard library.
+func As(err error, target any) bool {
+	return errors.As(err, target)
+}
+
+// KindOf returns the kind of an error returned by a repository: NotFound when it wraps ErrNotFound,
+// Conflict when it wraps ErrConflict, PreconditionFailed when it wraps ErrVersionMismatch and Internal
+// for any other failure.
+func KindOf(err error) Kind {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return NotFound
+	case errors.Is(err, ErrConflict):
+		return Conflict
+	case errors.Is(err, ErrVersionMismatch):
+		return PreconditionFailed
+	default:
+		return Internal
+	}
+}
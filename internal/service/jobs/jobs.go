@@ -0,0 +1,66 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job runs a task periodically in the background until it is stopped
+type Job struct {
+	Name     string
+	Interval time.Duration
+	task     func(ctx context.Context) error
+	cancel   context.CancelFunc
+	done     chan struct{}
+	once     sync.Once
+}
+
+func New(name string, interval time.Duration, task func(ctx context.Context) error) *Job {
+	return &Job{
+		Name:     name,
+		Interval: interval,
+		task:     task,
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs the task once right away and then on every tick of the interval.
+func (job *Job) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	job.cancel = cancel
+
+	go func() {
+		defer close(job.done)
+
+		ticker := time.NewTicker(job.Interval)
+		defer ticker.Stop()
+
+		for {
+			job.run(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop cancels the running task and waits for the job to finish.
+func (job *Job) Stop() {
+	job.once.Do(func() {
+		if job.cancel == nil {
+			return
+		}
+		job.cancel()
+		<-job.done
+	})
+}
+
+func (job *Job) run(ctx context.Context) {
+	if err := job.task(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("job %s: %v", job.Name, err)
+	}
+}
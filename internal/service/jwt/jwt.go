@@ -0,0 +1,127 @@
+package jwt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformedToken   = errors.New("the token is malformed")
+	ErrInvalidSignature = errors.New("the token signature is invalid")
+	ErrExpiredToken     = errors.New("the token has expired")
+)
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+}
+
+// Claims are the registered claims carried by the access tokens.
+type Claims struct {
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// UserID returns the id of the user the token was issued to.
+func (c Claims) UserID() (uint, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 64)
+	if err != nil {
+		return 0, ErrMalformedToken
+	}
+	return uint(id), nil
+}
+
+// Manager signs and verifies HS256 JSON Web Tokens.
+type Manager struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func NewManager(secret string, ttl time.Duration) *Manager {
+	return &Manager{
+		secret: []byte(secret),
+		ttl:    ttl,
+	}
+}
+
+// Generate creates a signed token for the given user and returns it along with its expiration time.
+func (m *Manager) Generate(userID uint) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(m.ttl)
+	claims := Claims{
+		Subject:   strconv.FormatUint(uint64(userID), 10),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	}
+
+	headerJSON, err := json.Marshal(header{Algorithm: "HS256", Type: "JWT"})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	unsigned := encodeSegment(headerJSON) + "." + encodeSegment(claimsJSON)
+	return unsigned + "." + encodeSegment(m.sign(unsigned)), expiresAt, nil
+}
+
+// Parse verifies the signature and the expiration of the token and returns its claims.
+func (m *Manager) Parse(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrMalformedToken
+	}
+
+	headerJSON, err := decodeSegment(parts[0])
+	if err != nil {
+		return Claims{}, ErrMalformedToken
+	}
+	var h header
+	if err := json.Unmarshal(headerJSON, &h); err != nil || h.Algorithm != "HS256" {
+		return Claims{}, ErrMalformedToken
+	}
+
+	signature, err := decodeSegment(parts[2])
+	if err != nil {
+		return Claims{}, ErrMalformedToken
+	}
+	if !hmac.Equal(signature, m.sign(parts[0]+"."+parts[1])) {
+		return Claims{}, ErrInvalidSignature
+	}
+
+	claimsJSON, err := decodeSegment(parts[1])
+	if err != nil {
+		return Claims{}, ErrMalformedToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return Claims{}, ErrMalformedToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return Claims{}, ErrExpiredToken
+	}
+	return claims, nil
+}
+
+func (m *Manager) sign(unsigned string) []byte {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(unsigned))
+	return mac.Sum(nil)
+}
+
+func encodeSegment(segment []byte) string {
+	return base64.RawURLEncoding.EncodeToString(segment)
+}
+
+func decodeSegment(segment string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(segment)
+}
@@ -0,0 +1,164 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902) documents to JSON documents.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	MergePatchMediaType = "application/merge-patch+json"
+	JSONPatchMediaType  = "application/json-patch+json"
+)
+
+// ErrInvalidPatch is wrapped by the errors of patches that are not well formed
+var ErrInvalidPatch = errors.New("the patch is not valid")
+
+// ErrTestFailed is wrapped by the errors of JSON Patch 'test' operations whose value differs
+var ErrTestFailed = errors.New("the test operation failed")
+
+// Merge applies the JSON Merge Patch to the document. Members of the patch set to null are removed
+// from the document, objects are merged recursively and any other value replaces the current one.
+func Merge(document []byte, mergePatch []byte) ([]byte, error) {
+	var doc, p any
+	if err := json.Unmarshal(document, &doc); err != nil {
+		return nil, err
+	}
+	if err := decode(mergePatch, &p); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergeValue(doc, p))
+}
+
+func mergeValue(target any, p any) any {
+	patchObject, ok := p.(map[string]any)
+	if !ok {
+		return p
+	}
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergeValue(targetObject[name], value)
+	}
+	return targetObject
+}
+
+// Operation is an operation of a JSON Patch. Value is kept raw to tell a null value from a missing one.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Apply applies the operations of the JSON Patch to the document, in order. Either every operation is
+// applied or the document is left as it was.
+func Apply(document []byte, jsonPatch []byte) ([]byte, error) {
+	var doc any
+	if err := json.Unmarshal(document, &doc); err != nil {
+		return nil, err
+	}
+	var operations []Operation
+	if err := decode(jsonPatch, &operations); err != nil {
+		return nil, err
+	}
+
+	for i, operation := range operations {
+		var err error
+		doc, err = operation.apply(doc)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, operation.Op, operation.Path, err)
+		}
+	}
+	return json.Marshal(doc)
+}
+
+func (operation Operation) apply(doc any) (any, error) {
+	path, err := parsePointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operation.Op {
+	case "add", "replace", "test":
+		if len(operation.Value) == 0 {
+			return nil, fmt.Errorf("%w: the operation needs a value", ErrInvalidPatch)
+		}
+		var value any
+		if err := json.Unmarshal(operation.Value, &value); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		switch operation.Op {
+		case "add":
+			return add(doc, path, value)
+		case "replace":
+			return replace(doc, path, value)
+		default:
+			current, err := get(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !equal(current, value) {
+				return nil, ErrTestFailed
+			}
+			return doc, nil
+		}
+	case "remove":
+		return remove(doc, path)
+	case "move", "copy":
+		from, err := parsePointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if operation.Op == "copy" {
+			return add(doc, path, deepCopy(value))
+		}
+		if isPrefix(from, path) && len(from) < len(path) {
+			return nil, fmt.Errorf("%w: a value can't be moved into itself", ErrInvalidPatch)
+		}
+		doc, err = remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	default:
+		return nil, fmt.Errorf("%w: unknown operation '%s'", ErrInvalidPatch, operation.Op)
+	}
+}
+
+// decode parses a patch, whose syntax errors wrap ErrInvalidPatch.
+func decode(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	if decoder.More() {
+		return fmt.Errorf("%w: unexpected data after the patch", ErrInvalidPatch)
+	}
+	return nil
+}
+
+func equal(a any, b any) bool {
+	aJSON, errA := json.Marshal(a)
+	bJSON, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(aJSON, bJSON)
+}
+
+func deepCopy(value any) any {
+	data, _ := json.Marshal(value)
+	var copied any
+	json.Unmarshal(data, &copied)
+	return copied
+}
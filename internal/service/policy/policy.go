@@ -0,0 +1,79 @@
+// Package policy decides what an authenticated user is allowed to do based on its role.
+// Handlers consult it to reject requests early, and services consult it again once the
+// affected resource has been loaded.
+package policy
+
+import (
+	"github.com/cortzero/go-postgres-blog/internal/model/auth"
+	"github.com/cortzero/go-postgres-blog/internal/model/comment"
+	"github.com/cortzero/go-postgres-blog/internal/model/post"
+	"github.com/cortzero/go-postgres-blog/internal/model/user"
+)
+
+func CanCreatePost(principal auth.Principal) bool {
+	return principal.Role.Can(user.PermissionCreatePost)
+}
+
+// CanViewPost reports whether the post is visible to the principal. Published posts are public while
+// the rest are only visible to those who can edit them. Anonymous requests use the zero Principal.
+func CanViewPost(principal auth.Principal, p post.Post) bool {
+	return p.Status == post.StatusPublished || CanEditPost(principal, p)
+}
+
+// CanListUnpublished reports whether the principal can list posts that are not published. Editors and
+// admins can list anybody's, while authors can only list their own.
+func CanListUnpublished(principal auth.Principal, authorId *uint) bool {
+	if principal.Role.Can(user.PermissionEditAnyPost) {
+		return true
+	}
+	return authorId != nil && *authorId == principal.UserID && principal.Role.Can(user.PermissionEditOwnPost)
+}
+
+func CanEditPost(principal auth.Principal, p post.Post) bool {
+	return canOnPost(principal, p, user.PermissionEditOwnPost, user.PermissionEditAnyPost)
+}
+
+func CanPublishPost(principal auth.Principal, p post.Post) bool {
+	return canOnPost(principal, p, user.PermissionPublishOwnPost, user.PermissionPublishAnyPost)
+}
+
+func CanDeletePost(principal auth.Principal, p post.Post) bool {
+	return canOnPost(principal, p, user.PermissionDeleteOwnPost, user.PermissionDeleteAnyPost)
+}
+
+// CanEditComment reports whether the principal can change the body of the comment, which only its author can do.
+func CanEditComment(principal auth.Principal, c comment.Comment) bool {
+	return c.UserID == principal.UserID
+}
+
+// CanDeleteComment reports whether the principal can delete the comment. Moderators can delete any comment.
+func CanDeleteComment(principal auth.Principal, c comment.Comment) bool {
+	return c.UserID == principal.UserID || principal.Role.Can(user.PermissionModerateComments)
+}
+
+// CanManageUser reports whether the principal can update or delete the account with the given id.
+// Users can always manage their own account.
+func CanManageUser(principal auth.Principal, userId uint) bool {
+	return principal.UserID == userId || principal.Role.Can(user.PermissionManageUsers)
+}
+
+func CanChangeRoles(principal auth.Principal) bool {
+	return principal.Role.Can(user.PermissionManageUsers)
+}
+
+// CanReassignPosts reports whether the principal can give the posts of a deleted user to another user.
+func CanReassignPosts(principal auth.Principal) bool {
+	return principal.Role.Can(user.PermissionManageUsers)
+}
+
+// CanManageTrash reports whether the principal can list the deleted posts and users and restore them.
+func CanManageTrash(principal auth.Principal) bool {
+	return principal.Role.Can(user.PermissionManageTrash)
+}
+
+func canOnPost(principal auth.Principal, p post.Post, ownPermission user.Permission, anyPermission user.Permission) bool {
+	if principal.Role.Can(anyPermission) {
+		return true
+	}
+	return p.UserID == principal.UserID && principal.Role.Can(ownPermission)
+}
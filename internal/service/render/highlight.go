@@ -0,0 +1,204 @@
+package render
+
+import (
+	"html"
+	"strings"
+)
+
+// language describes the lexical elements of a programming language needed to highlight it
+type language struct {
+	keywords     []string
+	lineComments []string
+	blockComment [2]string
+	quotes       string
+}
+
+var (
+	goLanguage = language{
+		keywords: []string{"break", "case", "chan", "const", "continue", "default", "defer", "else", "fallthrough",
+			"for", "func", "go", "goto", "if", "import", "interface", "map", "package", "range", "return", "select",
+			"struct", "switch", "type", "var", "nil", "true", "false", "iota"},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+	}
+	javascriptLanguage = language{
+		keywords: []string{"async", "await", "break", "case", "catch", "class", "const", "continue", "default", "delete",
+			"do", "else", "export", "extends", "finally", "for", "from", "function", "if", "import", "in", "instanceof",
+			"interface", "let", "new", "null", "of", "return", "static", "super", "switch", "this", "throw", "true",
+			"false", "try", "type", "typeof", "undefined", "var", "void", "while", "yield"},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+	}
+	pythonLanguage = language{
+		keywords: []string{"and", "as", "assert", "async", "await", "break", "class", "continue", "def", "del", "elif",
+			"else", "except", "False", "finally", "for", "from", "global", "if", "import", "in", "is", "lambda", "None",
+			"nonlocal", "not", "or", "pass", "raise", "return", "True", "try", "while", "with", "yield"},
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	}
+	sqlLanguage = language{
+		keywords: []string{"select", "from", "where", "and", "or", "not", "insert", "into", "values", "update", "set",
+			"delete", "create", "table", "index", "alter", "drop", "add", "column", "primary", "key", "foreign",
+			"references", "join", "left", "right", "inner", "outer", "on", "group", "by", "order", "having", "limit",
+			"offset", "as", "distinct", "null", "is", "in", "exists", "between", "like", "case", "when", "then", "else",
+			"end", "returning", "with", "union", "all", "asc", "desc", "default", "constraint", "unique", "true", "false"},
+		lineComments: []string{"--"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "'\"",
+	}
+	shellLanguage = language{
+		keywords: []string{"if", "then", "else", "elif", "fi", "for", "while", "until", "do", "done", "case", "esac",
+			"in", "function", "return", "export", "local", "echo", "exit"},
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	}
+	cLanguage = language{
+		keywords: []string{"auto", "bool", "break", "case", "char", "class", "const", "continue", "default", "do",
+			"double", "else", "enum", "extends", "final", "float", "for", "if", "implements", "import", "int", "long",
+			"namespace", "new", "null", "nullptr", "package", "private", "protected", "public", "return", "short",
+			"signed", "sizeof", "static", "struct", "switch", "this", "throw", "true", "false", "try", "catch",
+			"typedef", "union", "unsigned", "using", "void", "volatile", "while"},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'",
+	}
+	rustLanguage = language{
+		keywords: []string{"as", "async", "await", "break", "const", "continue", "crate", "else", "enum", "extern",
+			"false", "fn", "for", "if", "impl", "in", "let", "loop", "match", "mod", "move", "mut", "pub", "ref",
+			"return", "self", "Self", "static", "struct", "super", "trait", "true", "type", "unsafe", "use", "where",
+			"while"},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"",
+	}
+	jsonLanguage = language{
+		keywords: []string{"true", "false", "null"},
+		quotes:   "\"",
+	}
+)
+
+// languages maps the names used in the info string of fenced code blocks to their language
+var languages = map[string]*language{
+	"go": &goLanguage, "golang": &goLanguage,
+	"javascript": &javascriptLanguage, "js": &javascriptLanguage, "typescript": &javascriptLanguage, "ts": &javascriptLanguage,
+	"python": &pythonLanguage, "py": &pythonLanguage,
+	"sql": &sqlLanguage, "postgres": &sqlLanguage, "postgresql": &sqlLanguage,
+	"bash": &shellLanguage, "sh": &shellLanguage, "shell": &shellLanguage, "zsh": &shellLanguage,
+	"c": &cLanguage, "cpp": &cLanguage, "c++": &cLanguage, "java": &cLanguage, "csharp": &cLanguage, "cs": &cLanguage,
+	"rust": &rustLanguage, "rs": &rustLanguage,
+	"json": &jsonLanguage,
+}
+
+// Highlight escapes the code and wraps its comments, strings, numbers and keywords in spans with the
+// classes hl-comment, hl-string, hl-number and hl-keyword. Code in an unknown language is only escaped.
+func Highlight(lang string, code string) string {
+	l, ok := languages[strings.ToLower(lang)]
+	if !ok {
+		return html.EscapeString(code)
+	}
+
+	// SQL keywords are case insensitive
+	caseInsensitive := l == &sqlLanguage
+	keywords := make(map[string]bool, len(l.keywords))
+	for _, keyword := range l.keywords {
+		keywords[keyword] = true
+	}
+
+	var b strings.Builder
+	span := func(class string, text string) {
+		b.WriteString(`<span class="hl-` + class + `">` + html.EscapeString(text) + `</span>`)
+	}
+
+	for i := 0; i < len(code); {
+		rest := code[i:]
+
+		if end := l.commentEnd(rest); end > 0 {
+			span("comment", rest[:end])
+			i += end
+			continue
+		}
+
+		c := code[i]
+		if strings.IndexByte(l.quotes, c) >= 0 {
+			end := stringEnd(rest, c)
+			span("string", rest[:end])
+			i += end
+			continue
+		}
+
+		if isASCIIDigit(c) && (i == 0 || !isWordByte(code[i-1])) {
+			end := 1
+			for end < len(rest) && (isWordByte(rest[end]) || rest[end] == '.') {
+				end++
+			}
+			span("number", rest[:end])
+			i += end
+			continue
+		}
+
+		if isWordByte(c) {
+			end := 1
+			for end < len(rest) && isWordByte(rest[end]) {
+				end++
+			}
+			word := rest[:end]
+			if keywords[word] || (caseInsensitive && keywords[strings.ToLower(word)]) {
+				span("keyword", word)
+			} else {
+				b.WriteString(html.EscapeString(word))
+			}
+			i += end
+			continue
+		}
+
+		b.WriteString(html.EscapeString(string(c)))
+		i++
+	}
+	return b.String()
+}
+
+// commentEnd returns the length of the comment at the start of the code, or 0 if there is none.
+func (l *language) commentEnd(code string) int {
+	for _, prefix := range l.lineComments {
+		if strings.HasPrefix(code, prefix) {
+			if end := strings.IndexByte(code, '\n'); end >= 0 {
+				return end
+			}
+			return len(code)
+		}
+	}
+	if l.blockComment[0] != "" && strings.HasPrefix(code, l.blockComment[0]) {
+		start := len(l.blockComment[0])
+		if end := strings.Index(code[start:], l.blockComment[1]); end >= 0 {
+			return start + end + len(l.blockComment[1])
+		}
+		return len(code)
+	}
+	return 0
+}
+
+// stringEnd returns the length of the string literal at the start of the code, honoring backslash
+// escapes. Only backtick strings span several lines.
+func stringEnd(code string, quote byte) int {
+	for i := 1; i < len(code); i++ {
+		switch code[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case '\n':
+			if quote != '`' {
+				return i
+			}
+		case quote:
+			return i + 1
+		}
+	}
+	return len(code)
+}
+
+func isWordByte(c byte) bool {
+	return isASCIILetter(c) || isASCIIDigit(c) || c == '_' || c >= 0x80
+}
@@ -0,0 +1,384 @@
+package render
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	autolinkRegExp      = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^\s<>]*)>`)
+	emailAutolinkRegExp = regexp.MustCompile(`^<([a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*)>`)
+)
+
+func (r *markdownRenderer) renderInline(text string) string {
+	return renderInline(text, true)
+}
+
+// renderInline renders the spans of a block. Links are not rendered inside the text of another link.
+func renderInline(text string, links bool) string {
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch c {
+		case '\\':
+			if i+1 < len(text) && text[i+1] == '\n' {
+				b.WriteString("<br>\n")
+				i += 2
+				continue
+			}
+			if i+1 < len(text) && isASCIIPunctuation(text[i+1]) {
+				b.WriteString(html.EscapeString(text[i+1 : i+2]))
+				i += 2
+				continue
+			}
+		case '`':
+			if end, ok := renderCodeSpan(&b, text, i); ok {
+				i = end
+				continue
+			}
+			// An unmatched run of backticks is literal text
+			end := i
+			for end < len(text) && text[end] == '`' {
+				end++
+			}
+			b.WriteString(text[i:end])
+			i = end
+			continue
+		case '!':
+			if i+1 < len(text) && text[i+1] == '[' {
+				if l, ok := parseLink(text, i+1); ok {
+					writeImage(&b, l)
+					i = l.end
+					continue
+				}
+			}
+		case '[':
+			if l, ok := parseLink(text, i); ok && links {
+				if IsSafeURL(l.destination) {
+					writeLinkStart(&b, l.destination, l.title)
+					b.WriteString(renderInline(l.label, false))
+					b.WriteString("</a>")
+				} else {
+					b.WriteString(renderInline(l.label, false))
+				}
+				i = l.end
+				continue
+			}
+		case '<':
+			if matches := autolinkRegExp.FindStringSubmatch(text[i:]); matches != nil && links && IsSafeURL(matches[1]) {
+				writeLinkStart(&b, matches[1], "")
+				b.WriteString(html.EscapeString(matches[1]) + "</a>")
+				i += len(matches[0])
+				continue
+			}
+			if matches := emailAutolinkRegExp.FindStringSubmatch(text[i:]); matches != nil && links {
+				writeLinkStart(&b, "mailto:"+matches[1], "")
+				b.WriteString(html.EscapeString(matches[1]) + "</a>")
+				i += len(matches[0])
+				continue
+			}
+		case '*', '_', '~':
+			if end, ok := renderEmphasis(&b, text, i, links); ok {
+				i = end
+				continue
+			}
+			// An unmatched run of delimiters is literal text
+			end := i
+			for end < len(text) && text[end] == c {
+				end++
+			}
+			b.WriteString(text[i:end])
+			i = end
+			continue
+		case ' ':
+			end := i
+			for end < len(text) && text[end] == ' ' {
+				end++
+			}
+			// Two or more spaces at the end of a line make a hard line break, fewer are dropped
+			if end < len(text) && text[end] == '\n' {
+				if end-i >= 2 {
+					b.WriteString("<br>")
+				}
+				i = end
+				continue
+			}
+			b.WriteString(text[i:end])
+			i = end
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(text[i:])
+		b.WriteString(html.EscapeString(text[i : i+size]))
+		i += size
+	}
+	return b.String()
+}
+
+// renderCodeSpan renders the code span opened by the run of backticks at start, which must be closed by
+// a run of the same length. It returns the position after the span.
+func renderCodeSpan(b *strings.Builder, text string, start int) (int, bool) {
+	n := 0
+	for start+n < len(text) && text[start+n] == '`' {
+		n++
+	}
+	for i := start + n; i < len(text); {
+		if text[i] != '`' {
+			i++
+			continue
+		}
+		run := 0
+		for i+run < len(text) && text[i+run] == '`' {
+			run++
+		}
+		if run == n {
+			code := strings.ReplaceAll(text[start+n:i], "\n", " ")
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+				code = code[1 : len(code)-1]
+			}
+			b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+			return i + run, true
+		}
+		i += run
+	}
+	return start, false
+}
+
+// renderEmphasis renders the emphasis, strong emphasis or strikethrough opened by the run of delimiters
+// at start. It returns the position after the closing run.
+func renderEmphasis(b *strings.Builder, text string, start int, links bool) (int, bool) {
+	c := text[start]
+	n := 0
+	for start+n < len(text) && text[start+n] == c {
+		n++
+	}
+
+	// The opening run must be followed by a non-space character, and '_' can't open inside a word
+	if start+n >= len(text) || isSpaceAt(text, start+n) {
+		return start, false
+	}
+	if c == '_' && start > 0 && isAlphanumericBefore(text, start) {
+		return start, false
+	}
+
+	size := min(n, 3)
+	if c == '~' && n != 2 {
+		return start, false
+	}
+
+	// Falling back to a shorter emphasis when there is no closer for the whole run
+	for ; size > 0; size-- {
+		closer := findEmphasisCloser(text, start+n, c, size)
+		if closer < 0 {
+			if c == '~' {
+				break
+			}
+			continue
+		}
+
+		// Delimiters of the opening run beyond the ones matched are literal text
+		tags := emphasisTags(c, size)
+		b.WriteString(text[start : start+n-size])
+		for _, tag := range tags {
+			b.WriteString("<" + tag + ">")
+		}
+		b.WriteString(renderInline(text[start+n:closer], links))
+		for i := len(tags) - 1; i >= 0; i-- {
+			b.WriteString("</" + tags[i] + ">")
+		}
+		return closer + size, true
+	}
+	return start, false
+}
+
+func emphasisTags(c byte, size int) []string {
+	switch {
+	case c == '~':
+		return []string{"del"}
+	case size == 3:
+		return []string{"em", "strong"}
+	case size == 2:
+		return []string{"strong"}
+	default:
+		return []string{"em"}
+	}
+}
+
+// findEmphasisCloser returns the position of the first run of exactly size delimiters after from that
+// can close an emphasis, or -1 if there is none. Code spans are skipped.
+func findEmphasisCloser(text string, from int, c byte, size int) int {
+	for i := from; i < len(text); {
+		if text[i] == '`' {
+			var discard strings.Builder
+			if end, ok := renderCodeSpan(&discard, text, i); ok {
+				i = end
+				continue
+			}
+		}
+		if text[i] != c {
+			i++
+			continue
+		}
+		run := 0
+		for i+run < len(text) && text[i+run] == c {
+			run++
+		}
+		canClose := i > from && !isSpaceAt(text, i-1)
+		if c == '_' && i+run < len(text) && isAlphanumericAt(text, i+run) {
+			canClose = false
+		}
+		if canClose && run == size {
+			return i
+		}
+		i += run
+	}
+	return -1
+}
+
+type link struct {
+	label       string
+	destination string
+	title       string
+	end         int
+}
+
+// parseLink parses an inline link '[label](destination "title")' starting at the '['.
+func parseLink(text string, start int) (link, bool) {
+	// Finding the bracket that closes the label
+	depth := 0
+	closing := -1
+	for i := start; i < len(text) && closing < 0; i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closing = i
+			}
+		}
+	}
+	if closing < 0 || closing+1 >= len(text) || text[closing+1] != '(' {
+		return link{}, false
+	}
+
+	l := link{label: text[start+1 : closing]}
+	i := skipSpaces(text, closing+2)
+
+	// Destination, either between angle brackets or up to a space or the closing parenthesis
+	if i < len(text) && text[i] == '<' {
+		end := strings.IndexAny(text[i+1:], ">\n")
+		if end < 0 || text[i+1+end] != '>' {
+			return link{}, false
+		}
+		l.destination = text[i+1 : i+1+end]
+		i += end + 2
+	} else {
+		parens := 0
+		begin := i
+		for ; i < len(text); i++ {
+			ch := text[i]
+			if ch == '\\' && i+1 < len(text) {
+				i++
+				continue
+			}
+			if ch == ' ' || ch == '\n' || ch < ' ' {
+				break
+			}
+			if ch == '(' {
+				parens++
+			} else if ch == ')' {
+				if parens == 0 {
+					break
+				}
+				parens--
+			}
+		}
+		l.destination = text[begin:i]
+	}
+	l.destination = unescapeBackslashes(l.destination)
+
+	// Optional title
+	i = skipSpaces(text, i)
+	if i < len(text) && (text[i] == '"' || text[i] == '\'' || text[i] == '(') {
+		closer := text[i]
+		if closer == '(' {
+			closer = ')'
+		}
+		end := strings.IndexByte(text[i+1:], closer)
+		if end < 0 {
+			return link{}, false
+		}
+		l.title = unescapeBackslashes(text[i+1 : i+1+end])
+		i = skipSpaces(text, i+end+2)
+	}
+
+	if i >= len(text) || text[i] != ')' {
+		return link{}, false
+	}
+	l.end = i + 1
+	return l, true
+}
+
+func writeLinkStart(b *strings.Builder, destination string, title string) {
+	b.WriteString(`<a href="` + html.EscapeString(destination) + `"`)
+	if title != "" {
+		b.WriteString(` title="` + html.EscapeString(title) + `"`)
+	}
+	b.WriteString(">")
+}
+
+func writeImage(b *strings.Builder, l link) {
+	alt := stripTags(renderInline(l.label, false))
+	if !IsSafeURL(l.destination) {
+		b.WriteString(alt)
+		return
+	}
+	b.WriteString(`<img src="` + html.EscapeString(l.destination) + `" alt="` + alt + `"`)
+	if l.title != "" {
+		b.WriteString(` title="` + html.EscapeString(l.title) + `"`)
+	}
+	b.WriteString(">")
+}
+
+func unescapeBackslashes(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) && isASCIIPunctuation(text[i+1]) {
+			i++
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+func skipSpaces(text string, i int) int {
+	for i < len(text) && (text[i] == ' ' || text[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+func isASCIIPunctuation(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isSpaceAt(text string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	return unicode.IsSpace(r)
+}
+
+func isAlphanumericAt(text string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isAlphanumericBefore(text string, i int) bool {
+	r, _ := utf8.DecodeLastRuneInString(text[:i])
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,393 @@
+package render
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cortzero/go-postgres-blog/internal/service/slug"
+)
+
+// The renderer supports the common subset of CommonMark and GitHub Flavored Markdown: ATX and setext
+// headings, paragraphs, block quotes, bullet and ordered lists, fenced and indented code blocks,
+// thematic breaks, tables, emphasis, strikethrough, code spans, links, images and autolinks.
+// Raw HTML is escaped like any other text.
+
+var (
+	atxHeadingRegExp     = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	setextHeadingRegExp  = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	thematicBreakRegExp  = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fenceRegExp          = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`]*)$")
+	blockQuoteRegExp     = regexp.MustCompile(`^ {0,3}> ?`)
+	listItemRegExp       = regexp.MustCompile(`^( {0,3})([-*+]|(\d{1,9})[.)])([ \t]+|$)`)
+	tableDelimiterRegExp = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+)
+
+type markdownRenderer struct {
+	headings []Heading
+	anchors  map[string]bool
+}
+
+func newMarkdownRenderer() *markdownRenderer {
+	return &markdownRenderer{anchors: make(map[string]bool)}
+}
+
+func (r *markdownRenderer) render(source string) string {
+	source = strings.ReplaceAll(strings.ReplaceAll(source, "\r\n", "\n"), "\t", "    ")
+	var b strings.Builder
+	r.renderBlocks(&b, strings.Split(source, "\n"), false)
+	return b.String()
+}
+
+// renderBlocks renders the lines as a sequence of blocks. In tight lists the paragraphs are not
+// wrapped in <p> elements.
+func (r *markdownRenderer) renderBlocks(b *strings.Builder, lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+		case fenceRegExp.MatchString(line):
+			i = r.renderFencedCode(b, lines, i)
+		case atxHeadingRegExp.MatchString(line):
+			matches := atxHeadingRegExp.FindStringSubmatch(line)
+			r.renderHeading(b, len(matches[1]), matches[2])
+			i++
+		case thematicBreakRegExp.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+		case blockQuoteRegExp.MatchString(line):
+			i = r.renderBlockQuote(b, lines, i)
+		case listItemRegExp.MatchString(line):
+			i = r.renderList(b, lines, i)
+		case indentation(line) >= 4:
+			i = r.renderIndentedCode(b, lines, i)
+		case i+1 < len(lines) && strings.Contains(line, "|") && tableDelimiterRegExp.MatchString(lines[i+1]) &&
+			strings.Contains(lines[i+1], "-"):
+			i = r.renderTable(b, lines, i)
+		default:
+			i = r.renderParagraph(b, lines, i, tight)
+		}
+	}
+}
+
+func (r *markdownRenderer) renderHeading(b *strings.Builder, level int, text string) {
+	content := r.renderInline(strings.TrimSpace(text))
+	plain := html.UnescapeString(stripTags(content))
+
+	anchor := slug.Make(plain)
+	if anchor == "" {
+		anchor = "section"
+	}
+	// Numbering goes on until a free anchor is found, since a numbered anchor may be the slug of another heading
+	base := anchor
+	for n := 2; r.anchors[anchor]; n++ {
+		anchor = base + "-" + strconv.Itoa(n)
+	}
+	r.anchors[anchor] = true
+	r.headings = append(r.headings, Heading{Level: level, Text: plain, Anchor: anchor})
+
+	tag := strconv.Itoa(level)
+	b.WriteString(`<h` + tag + ` id="` + html.EscapeString(anchor) + `">` + content + `</h` + tag + ">\n")
+}
+
+func (r *markdownRenderer) renderFencedCode(b *strings.Builder, lines []string, start int) int {
+	matches := fenceRegExp.FindStringSubmatch(lines[start])
+	indent, fence := len(matches[1]), matches[2]
+	lang := ""
+	if fields := strings.Fields(matches[3]); len(fields) > 0 {
+		lang = fields[0]
+	}
+
+	var code []string
+	i := start + 1
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if indentation(lines[i]) < 4 && strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			i++
+			break
+		}
+		code = append(code, removeIndentation(lines[i], indent))
+	}
+
+	r.writeCode(b, lang, strings.Join(code, "\n"))
+	return i
+}
+
+func (r *markdownRenderer) renderIndentedCode(b *strings.Builder, lines []string, start int) int {
+	var code []string
+	i := start
+	for ; i < len(lines) && (isBlank(lines[i]) || indentation(lines[i]) >= 4); i++ {
+		code = append(code, removeIndentation(lines[i], 4))
+	}
+	// Trailing blank lines are not part of the block
+	end := len(code)
+	for end > 0 && isBlank(code[end-1]) {
+		end--
+	}
+	r.writeCode(b, "", strings.Join(code[:end], "\n"))
+	return start + end
+}
+
+func (r *markdownRenderer) writeCode(b *strings.Builder, lang string, code string) {
+	if lang != "" {
+		b.WriteString(`<pre><code class="language-` + html.EscapeString(lang) + `">`)
+	} else {
+		b.WriteString("<pre><code>")
+	}
+	b.WriteString(Highlight(lang, code))
+	if code != "" {
+		b.WriteString("\n")
+	}
+	b.WriteString("</code></pre>\n")
+}
+
+func (r *markdownRenderer) renderBlockQuote(b *strings.Builder, lines []string, start int) int {
+	var content []string
+	i := start
+	for ; i < len(lines) && !isBlank(lines[i]); i++ {
+		if loc := blockQuoteRegExp.FindStringIndex(lines[i]); loc != nil {
+			content = append(content, lines[i][loc[1]:])
+			continue
+		}
+		// Lazy continuation of a paragraph inside the quote
+		if startsBlock(lines[i]) {
+			break
+		}
+		content = append(content, lines[i])
+	}
+
+	b.WriteString("<blockquote>\n")
+	r.renderBlocks(b, content, false)
+	b.WriteString("</blockquote>\n")
+	return i
+}
+
+// renderList renders the consecutive items of the same kind of list. The lines of an item are those
+// indented at least as much as its content, and the list is loose when blank lines separate its blocks.
+func (r *markdownRenderer) renderList(b *strings.Builder, lines []string, start int) int {
+	first := listItemRegExp.FindStringSubmatch(lines[start])
+	ordered := first[3] != ""
+	delimiter := first[2][len(first[2])-1:]
+
+	var items [][]string
+	loose := false
+	i := start
+	for i < len(lines) {
+		if !continuesList(lines[i], ordered, delimiter) {
+			break
+		}
+		matches := listItemRegExp.FindStringSubmatch(lines[i])
+
+		contentIndent := len(matches[0])
+		if matches[4] == "" || len(matches[4]) > 4 {
+			contentIndent = len(matches[1]) + len(matches[2]) + 1
+		}
+		item := []string{strings.TrimLeft(lines[i][len(matches[1])+len(matches[2]):], " ")}
+		i++
+
+		for i < len(lines) {
+			if isBlank(lines[i]) {
+				// A blank line continues the item only if an indented line follows
+				next := i
+				for next < len(lines) && isBlank(lines[next]) {
+					next++
+				}
+				if next < len(lines) && indentation(lines[next]) >= contentIndent {
+					loose = true
+					for ; i < next; i++ {
+						item = append(item, "")
+					}
+					continue
+				}
+				if next < len(lines) && continuesList(lines[next], ordered, delimiter) {
+					loose = true
+				}
+				break
+			}
+			if indentation(lines[i]) >= contentIndent {
+				item = append(item, removeIndentation(lines[i], contentIndent))
+				i++
+				continue
+			}
+			// Lazy continuation of the paragraph of the item
+			if startsBlock(lines[i]) || continuesList(lines[i], ordered, delimiter) {
+				break
+			}
+			item = append(item, lines[i])
+			i++
+		}
+		items = append(items, item)
+
+		for i < len(lines) && isBlank(lines[i]) {
+			i++
+		}
+	}
+
+	if ordered {
+		number, _ := strconv.Atoi(first[3])
+		if number != 1 {
+			b.WriteString(`<ol start="` + strconv.Itoa(number) + `">` + "\n")
+		} else {
+			b.WriteString("<ol>\n")
+		}
+	} else {
+		b.WriteString("<ul>\n")
+	}
+	for _, item := range items {
+		b.WriteString("<li>")
+		r.renderBlocks(b, item, !loose)
+		b.WriteString("</li>\n")
+	}
+	if ordered {
+		b.WriteString("</ol>\n")
+	} else {
+		b.WriteString("</ul>\n")
+	}
+	return i
+}
+
+func (r *markdownRenderer) renderTable(b *strings.Builder, lines []string, start int) int {
+	header := splitTableRow(lines[start])
+	var aligns []string
+	for _, cell := range splitTableRow(lines[start+1]) {
+		cell = strings.TrimSpace(cell)
+		switch {
+		case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, "center")
+		case strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, "right")
+		case strings.HasPrefix(cell, ":"):
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+
+	writeRow := func(cells []string, tag string) {
+		b.WriteString("<tr>")
+		for j := range header {
+			cell := ""
+			if j < len(cells) {
+				cell = cells[j]
+			}
+			b.WriteString("<" + tag)
+			if j < len(aligns) && aligns[j] != "" {
+				b.WriteString(` align="` + aligns[j] + `"`)
+			}
+			b.WriteString(">" + r.renderInline(strings.TrimSpace(cell)) + "</" + tag + ">")
+		}
+		b.WriteString("</tr>\n")
+	}
+
+	b.WriteString("<table>\n<thead>\n")
+	writeRow(header, "th")
+	b.WriteString("</thead>\n")
+
+	i := start + 2
+	if i < len(lines) && !isBlank(lines[i]) && strings.Contains(lines[i], "|") {
+		b.WriteString("<tbody>\n")
+		for ; i < len(lines) && !isBlank(lines[i]) && strings.Contains(lines[i], "|"); i++ {
+			writeRow(splitTableRow(lines[i]), "td")
+		}
+		b.WriteString("</tbody>\n")
+	}
+	b.WriteString("</table>\n")
+	return i
+}
+
+func (r *markdownRenderer) renderParagraph(b *strings.Builder, lines []string, start int, tight bool) int {
+	content := []string{strings.TrimSpace(lines[start])}
+	i := start + 1
+	for ; i < len(lines) && !isBlank(lines[i]); i++ {
+		if matches := setextHeadingRegExp.FindStringSubmatch(lines[i]); matches != nil {
+			level := 2
+			if matches[1][0] == '=' {
+				level = 1
+			}
+			r.renderHeading(b, level, strings.Join(content, "\n"))
+			return i + 1
+		}
+		if startsBlock(lines[i]) {
+			break
+		}
+		content = append(content, strings.TrimLeft(lines[i], " "))
+	}
+
+	text := r.renderInline(strings.TrimRight(strings.Join(content, "\n"), " "))
+	if tight {
+		b.WriteString(text)
+	} else {
+		b.WriteString("<p>" + text + "</p>\n")
+	}
+	return i
+}
+
+// continuesList reports whether the line is a new item of a list of the same kind.
+func continuesList(line string, ordered bool, delimiter string) bool {
+	matches := listItemRegExp.FindStringSubmatch(line)
+	if matches == nil || thematicBreakRegExp.MatchString(line) {
+		return false
+	}
+	return (matches[3] != "") == ordered && matches[2][len(matches[2])-1:] == delimiter
+}
+
+// startsBlock reports whether the line interrupts a paragraph.
+func startsBlock(line string) bool {
+	if atxHeadingRegExp.MatchString(line) || thematicBreakRegExp.MatchString(line) ||
+		fenceRegExp.MatchString(line) || blockQuoteRegExp.MatchString(line) {
+		return true
+	}
+	// Only bullet items and ordered items starting at 1 interrupt a paragraph, and not when they are empty
+	if matches := listItemRegExp.FindStringSubmatch(line); matches != nil {
+		return matches[4] != "" && (matches[3] == "" || matches[3] == "1")
+	}
+	return false
+}
+
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) && line[i+1] == '|' {
+			cell.WriteByte('|')
+			i++
+			continue
+		}
+		if line[i] == '|' {
+			cells = append(cells, cell.String())
+			cell.Reset()
+			continue
+		}
+		cell.WriteByte(line[i])
+	}
+	return append(cells, cell.String())
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func removeIndentation(line string, n int) string {
+	if indentation(line) < n {
+		return strings.TrimLeft(line, " ")
+	}
+	return line[n:]
+}
+
+var tagRegExp = regexp.MustCompile(`<[^>]*>`)
+
+func stripTags(text string) string {
+	return tagRegExp.ReplaceAllString(text, "")
+}
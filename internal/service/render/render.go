@@ -0,0 +1,60 @@
+// Package render converts the body of a post to sanitized HTML. Markdown gets heading anchors, a table
+// of contents and highlighted code blocks, and the output of every format goes through the allowlist
+// sanitizer so that it can be embedded in a page as it is.
+package render
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Format is the markup language of a body
+type Format string
+
+const (
+	FormatMarkdown Format = "markdown"
+	FormatPlain    Format = "plain"
+	FormatHTML     Format = "html"
+)
+
+func (f Format) IsValid() bool {
+	return f == FormatMarkdown || f == FormatPlain || f == FormatHTML
+}
+
+// Result is the rendered HTML of a body along with the table of contents built from its headings
+type Result struct {
+	HTML string
+	TOC  []Heading
+}
+
+// Render converts the source written in the format to sanitized HTML.
+func Render(format Format, source string) (Result, error) {
+	switch format {
+	case FormatMarkdown:
+		r := newMarkdownRenderer()
+		output := r.render(source)
+		return Result{HTML: Sanitize(output), TOC: buildTOC(r.headings)}, nil
+	case FormatPlain:
+		return Result{HTML: Plain(source), TOC: []Heading{}}, nil
+	case FormatHTML:
+		return Result{HTML: Sanitize(source), TOC: []Heading{}}, nil
+	default:
+		return Result{}, fmt.Errorf("unknown body format '%s'", format)
+	}
+}
+
+var paragraphBreakRegExp = regexp.MustCompile(`\n{2,}`)
+
+// Plain escapes the text and splits it into paragraphs on blank lines, turning single line breaks
+// into <br>. Migration 0012 renders the existing posts with the same rules in SQL.
+func Plain(source string) string {
+	if source == "" {
+		return ""
+	}
+	text := html.EscapeString(strings.ReplaceAll(source, "\r\n", "\n"))
+	text = paragraphBreakRegExp.ReplaceAllString(text, "</p><p>")
+	text = strings.ReplaceAll(text, "\n", "<br>")
+	return "<p>" + text + "</p>"
+}
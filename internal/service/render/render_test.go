@@ -0,0 +1,144 @@
+package render
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"allowed markup", `<p>Hello <strong>world</strong></p>`, `<p>Hello <strong>world</strong></p>`},
+		{"script dropped with content", `<script>alert(1)</script><p>after</p>`, `<p>after</p>`},
+		{"uppercase script", `<SCRIPT>x</SCRIPT>ok`, `ok`},
+		{"self closing script", `<script/>ok`, `ok`},
+		{"style dropped", `<style>p{}</style>ok`, `ok`},
+		{"iframe dropped", `<iframe src="https://x"></iframe>ok`, `ok`},
+		{"textarea dropped", `<textarea><p>x</p></textarea>ok`, `ok`},
+		{"svg dropped", `<svg><script>alert(1)</script></svg>after`, `after`},
+		{"event handler", `<p onclick="alert(1)">text</p>`, `<p>text</p>`},
+		{"img event handler", `<img src="/a.png" alt="a" onerror="alert(1)">`, `<img src="/a.png" alt="a">`},
+		{"javascript href", `<a href="javascript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"mixed case href", `<a href="JaVaScRiPt:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"entity tab in href", `<a href="java&#x09;script:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"entity letter in href", `<a href="&#106;avascript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"single quoted href", `<a href='javascript:x'>q</a>`, `<a rel="nofollow noopener noreferrer">q</a>`},
+		{"unquoted href", `<a href=javascript:x>q</a>`, `<a rel="nofollow noopener noreferrer">q</a>`},
+		{"data image", `<img src="data:image/png;base64,AAAA" alt="a">`, `<img alt="a">`},
+		{"safe link", `<a href="https://example.com" title="t">x</a>`, `<a href="https://example.com" title="t" rel="nofollow noopener noreferrer">x</a>`},
+		{"mailto link", `<a href="mailto:a@b.c">m</a>`, `<a href="mailto:a@b.c" rel="nofollow noopener noreferrer">m</a>`},
+		{"escaped attribute", `<a href="x" title="&quot;><script>">y</a>`, `<a href="x" title="&#34;&gt;&lt;script&gt;" rel="nofollow noopener noreferrer">y</a>`},
+		{"unknown tag unwrapped", `<custom>kept <em>text</em></custom>`, `kept <em>text</em>`},
+		{"open tags closed", `<p>unclosed <em>emphasis`, `<p>unclosed <em>emphasis</em></p>`},
+		{"stray close tag", `</div>stray close`, `stray close`},
+		{"comment dropped", `<!-- comment --><p>x</p>`, `<p>x</p>`},
+		{"text escaped", `1 < 2 & 3 > 2`, `1 &lt; 2 &amp; 3 &gt; 2`},
+		{"highlight classes", `<code class="language-go hl-keyword">x</code>`, `<code class="language-go hl-keyword">x</code>`},
+		{"other class", `<code class="evil">x</code>`, `<code>x</code>`},
+		{"heading id", `<h2 id="ok-id">x</h2><h2 id="bad id">y</h2>`, `<h2 id="ok-id">x</h2><h2>y</h2>`},
+		{"list start", `<ol start="3"><li>x</li></ol><ol start="x"><li>y</li></ol>`, `<ol start="3"><li>x</li></ol><ol><li>y</li></ol>`},
+		{"cell align", `<td align="center">x</td><td align="javascript">y</td>`, `<td align="center">x</td><td>y</td>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.input); got != tt.want {
+				t.Errorf("Sanitize(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSafeURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com", true},
+		{"http://example.com", true},
+		{"mailto:a@b.c", true},
+		{"/relative", true},
+		{"relative/path", true},
+		{"./a:b", true},
+		{"#fragment", true},
+		{"?q=1", true},
+		{"//cdn.example.com/x", true},
+		{"javascript:alert(1)", false},
+		{"JAVASCRIPT:alert(1)", false},
+		{" javascript:alert(1)", false},
+		{"java\tscript:alert(1)", false},
+		{"java\nscript:alert(1)", false},
+		{"\x00javascript:alert(1)", false},
+		{"data:text/html,x", false},
+		{"vbscript:x", false},
+		{"file:///etc/passwd", false},
+		{"ftp://example.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsSafeURL(tt.url); got != tt.want {
+			t.Errorf("IsSafeURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestPlain(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"a", "<p>a</p>"},
+		{"a\nb", "<p>a<br>b</p>"},
+		{"a\r\nb", "<p>a<br>b</p>"},
+		{"a\n\nb", "<p>a</p><p>b</p>"},
+		{"a\n\n\n\nb", "<p>a</p><p>b</p>"},
+		{"<b>&</b>", "<p>&lt;b&gt;&amp;&lt;/b&gt;</p>"},
+	}
+
+	for _, tt := range tests {
+		if got := Plain(tt.input); got != tt.want {
+			t.Errorf("Plain(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestHeadingAnchors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"unique", "# Title\n## Sub", []string{"title", "sub"}},
+		{"repeated", "## A\n### B\n## A", []string{"a", "b", "a-2"}},
+		{"numbered collides with suffix", "# Foo\n# Foo\n# Foo 2", []string{"foo", "foo-2", "foo-2-2"}},
+		{"suffix taken first", "# Foo 2\n# Foo\n# Foo", []string{"foo-2", "foo", "foo-3"}},
+		{"no letters", "# !!!\n# ???", []string{"section", "section-2"}},
+		{"inline markup", "# Héllo *World*", []string{"hello-world"}},
+		{"escaped html", "# <script>x</script>", []string{"script-x-script"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Render(FormatMarkdown, tt.input)
+			if err != nil {
+				t.Fatalf("Render(%q) returned %v", tt.input, err)
+			}
+			if got := anchors(result.TOC); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("anchors of %q = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func anchors(headings []Heading) []string {
+	var list []string
+	for _, h := range headings {
+		list = append(list, h.Anchor)
+		list = append(list, anchors(h.Children)...)
+	}
+	return list
+}
@@ -0,0 +1,332 @@
+package render
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// allowedTags lists the elements kept by the sanitizer along with the attributes they may have
+var allowedTags = map[string][]string{
+	"a": {"href", "title"}, "img": {"src", "alt", "title"},
+	"p": nil, "br": nil, "hr": nil, "blockquote": nil, "div": nil,
+	"h1": {"id"}, "h2": {"id"}, "h3": {"id"}, "h4": {"id"}, "h5": {"id"}, "h6": {"id"},
+	"em": nil, "strong": nil, "i": nil, "b": nil, "u": nil, "del": nil, "s": nil, "sub": nil, "sup": nil,
+	"code": {"class"}, "pre": {"class"}, "span": {"class"},
+	"ul": nil, "ol": {"start"}, "li": nil,
+	"table": nil, "thead": nil, "tbody": nil, "tr": nil, "th": {"align"}, "td": {"align"},
+}
+
+// droppedTags are removed along with their content
+var droppedTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"noscript": true, "template": true, "textarea": true, "title": true, "svg": true, "math": true,
+}
+
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true}
+
+var (
+	urlSchemeRegExp = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9+.-]*):`)
+	classRegExp     = regexp.MustCompile(`^(hl-[a-z]+|language-[a-zA-Z0-9_+#-]+)$`)
+	idRegExp        = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+	numberRegExp    = regexp.MustCompile(`^\d{1,9}$`)
+	alignRegExp     = regexp.MustCompile(`^(left|center|right)$`)
+)
+
+// Sanitize keeps only the allowlisted elements and attributes of the HTML. Links may only use the http,
+// https and mailto schemes or be relative, unknown elements are unwrapped, and elements such as script
+// are removed with their content. The open elements are closed at the end.
+func Sanitize(source string) string {
+	var b strings.Builder
+	var open []string
+	dropping := ""
+
+	t := tokenizer{source: source}
+	for {
+		tok, ok := t.next()
+		if !ok {
+			break
+		}
+
+		if dropping != "" {
+			if tok.kind == endTagToken && tok.name == dropping {
+				dropping = ""
+			}
+			continue
+		}
+
+		switch tok.kind {
+		case textToken:
+			b.WriteString(html.EscapeString(html.UnescapeString(tok.text)))
+		case startTagToken:
+			if droppedTags[tok.name] {
+				if !tok.selfClosing {
+					dropping = tok.name
+				}
+				continue
+			}
+			attributes, allowed := allowedTags[tok.name]
+			if !allowed {
+				continue
+			}
+			b.WriteString("<" + tok.name)
+			for _, attr := range tok.attrs {
+				if value, ok := sanitizeAttribute(tok.name, attr, attributes); ok {
+					b.WriteString(" " + attr.name + `="` + html.EscapeString(value) + `"`)
+				}
+			}
+			if tok.name == "a" {
+				b.WriteString(` rel="nofollow noopener noreferrer"`)
+			}
+			b.WriteString(">")
+			if !voidTags[tok.name] {
+				open = append(open, tok.name)
+			}
+		case endTagToken:
+			// Closing an element that is not open is ignored, and closing one that is open closes the ones inside it
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != tok.name {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					b.WriteString("</" + open[j] + ">")
+				}
+				open = open[:i]
+				break
+			}
+		}
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i] + ">")
+	}
+	return b.String()
+}
+
+func sanitizeAttribute(tag string, attr attribute, allowed []string) (string, bool) {
+	found := false
+	for _, name := range allowed {
+		if name == attr.name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", false
+	}
+
+	value := html.UnescapeString(attr.value)
+	switch attr.name {
+	case "href", "src":
+		return value, IsSafeURL(value)
+	case "class":
+		for _, class := range strings.Fields(value) {
+			if !classRegExp.MatchString(class) {
+				return "", false
+			}
+		}
+		return value, true
+	case "id":
+		return value, idRegExp.MatchString(value)
+	case "start":
+		return value, numberRegExp.MatchString(value)
+	case "align":
+		return value, alignRegExp.MatchString(value)
+	default:
+		return value, true
+	}
+}
+
+// IsSafeURL reports whether the URL can be used in a link or an image: it must be relative or use
+// the http, https or mailto scheme.
+func IsSafeURL(url string) bool {
+	// Browsers ignore control characters and whitespace inside the scheme, e.g. "java\tscript:"
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, url)
+
+	matches := urlSchemeRegExp.FindStringSubmatch(cleaned)
+	if matches == nil {
+		return true
+	}
+	switch strings.ToLower(matches[1]) {
+	case "http", "https", "mailto":
+		return true
+	default:
+		return false
+	}
+}
+
+type tokenKind int
+
+const (
+	textToken tokenKind = iota
+	startTagToken
+	endTagToken
+)
+
+type attribute struct {
+	name  string
+	value string
+}
+
+type token struct {
+	kind        tokenKind
+	text        string
+	name        string
+	attrs       []attribute
+	selfClosing bool
+}
+
+// tokenizer splits HTML into text, start tags and end tags. Comments, doctypes and processing
+// instructions are skipped, and a '<' that doesn't start a tag is returned as text.
+type tokenizer struct {
+	source string
+	pos    int
+}
+
+func (t *tokenizer) next() (token, bool) {
+	for t.pos < len(t.source) {
+		rest := t.source[t.pos:]
+		if rest[0] != '<' {
+			end := strings.IndexByte(rest, '<')
+			if end < 0 {
+				end = len(rest)
+			}
+			t.pos += end
+			return token{kind: textToken, text: rest[:end]}, true
+		}
+
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				t.pos = len(t.source)
+			} else {
+				t.pos += 4 + end + 3
+			}
+			continue
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			t.skipPast('>')
+			continue
+		case len(rest) > 2 && rest[1] == '/' && isASCIILetter(rest[2]):
+			t.pos += 2
+			name := t.readName()
+			t.skipPast('>')
+			return token{kind: endTagToken, name: name}, true
+		case len(rest) > 1 && isASCIILetter(rest[1]):
+			if tok, ok := t.readStartTag(); ok {
+				return tok, true
+			}
+		}
+
+		// A lone '<' is text
+		t.pos++
+		return token{kind: textToken, text: "<"}, true
+	}
+	return token{}, false
+}
+
+// readStartTag reads the tag starting at the current '<'. If the tag is never closed, the position
+// is left unchanged and false is returned.
+func (t *tokenizer) readStartTag() (token, bool) {
+	start := t.pos
+	t.pos++
+	tok := token{kind: startTagToken, name: t.readName()}
+	for {
+		t.skipSpaces()
+		if t.pos >= len(t.source) {
+			t.pos = start
+			return token{}, false
+		}
+		switch t.source[t.pos] {
+		case '>':
+			t.pos++
+			return tok, true
+		case '/':
+			t.pos++
+			if t.pos < len(t.source) && t.source[t.pos] == '>' {
+				tok.selfClosing = true
+			}
+			continue
+		}
+
+		name := t.readAttributeName()
+		if name == "" {
+			t.pos++
+			continue
+		}
+		attr := attribute{name: name}
+		t.skipSpaces()
+		if t.pos < len(t.source) && t.source[t.pos] == '=' {
+			t.pos++
+			t.skipSpaces()
+			attr.value = t.readAttributeValue()
+		}
+		tok.attrs = append(tok.attrs, attr)
+	}
+}
+
+func (t *tokenizer) readName() string {
+	start := t.pos
+	for t.pos < len(t.source) && (isASCIILetter(t.source[t.pos]) || isASCIIDigit(t.source[t.pos]) || t.source[t.pos] == '-') {
+		t.pos++
+	}
+	return strings.ToLower(t.source[start:t.pos])
+}
+
+func (t *tokenizer) readAttributeName() string {
+	start := t.pos
+	for t.pos < len(t.source) && !strings.ContainsRune(" \t\n\r\f/>=\"'<", rune(t.source[t.pos])) {
+		t.pos++
+	}
+	return strings.ToLower(t.source[start:t.pos])
+}
+
+func (t *tokenizer) readAttributeValue() string {
+	if t.pos >= len(t.source) {
+		return ""
+	}
+	if quote := t.source[t.pos]; quote == '"' || quote == '\'' {
+		end := strings.IndexByte(t.source[t.pos+1:], quote)
+		if end < 0 {
+			value := t.source[t.pos+1:]
+			t.pos = len(t.source)
+			return value
+		}
+		value := t.source[t.pos+1 : t.pos+1+end]
+		t.pos += end + 2
+		return value
+	}
+	start := t.pos
+	for t.pos < len(t.source) && !strings.ContainsRune(" \t\n\r\f>", rune(t.source[t.pos])) {
+		t.pos++
+	}
+	return t.source[start:t.pos]
+}
+
+func (t *tokenizer) skipSpaces() {
+	for t.pos < len(t.source) && strings.ContainsRune(" \t\n\r\f", rune(t.source[t.pos])) {
+		t.pos++
+	}
+}
+
+func (t *tokenizer) skipPast(c byte) {
+	end := strings.IndexByte(t.source[t.pos:], c)
+	if end < 0 {
+		t.pos = len(t.source)
+		return
+	}
+	t.pos += end + 1
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isASCIIDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,29 @@
+package render
+
+// Heading is an entry of the table of contents, linking to the anchor of a heading of the body.
+// Lower level headings that follow it are nested as its children.
+type Heading struct {
+	Level    int       `json:"level"`
+	Text     string    `json:"text"`
+	Anchor   string    `json:"anchor"`
+	Children []Heading `json:"children,omitempty"`
+}
+
+// buildTOC nests the headings, listed in the order they appear, under the closest previous heading
+// of a higher level.
+func buildTOC(headings []Heading) []Heading {
+	toc := []Heading{}
+	for len(headings) > 0 {
+		first := headings[0]
+		end := 1
+		for end < len(headings) && headings[end].Level > first.Level {
+			end++
+		}
+		if end > 1 {
+			first.Children = buildTOC(headings[1:end])
+		}
+		toc = append(toc, first)
+		headings = headings[end:]
+	}
+	return toc
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/auth"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+)
+
+// actingPrincipal returns the authenticated user performing the operation, which the
+// authentication middleware stores in the request context.
+func actingPrincipal(ctx context.Context) (auth.Principal, *errors.CustomError) {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return auth.Principal{}, errors.NewCustomError(
+			errors.Unauthorized,
+			"UNAUTHORIZED",
+			"You must be logged in to perform this operation.",
+			"The request does not carry an authenticated user.",
+			time.Now(),
+		)
+	}
+	return principal, nil
+}
+
+func forbiddenError(message string, details string) *errors.CustomError {
+	return errors.NewCustomError(
+		errors.Forbidden,
+		"FORBIDDEN",
+		message,
+		details,
+		time.Now(),
+	)
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/auth"
+	"github.com/cortzero/go-postgres-blog/internal/model/user"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+	"github.com/cortzero/go-postgres-blog/internal/service/jwt"
+)
+
+// AuthService is a service layer component that manages user sessions. Access tokens are short-lived JWTs,
+// while refresh tokens are opaque, stored hashed and rotated every time they are used.
+type AuthService struct {
+	UserService     user.Service
+	RefreshTokens   auth.RefreshTokenRepository
+	Tokens          *jwt.Manager
+	RefreshTokenTTL time.Duration
+}
+
+func NewAuthService(userService user.Service, refreshTokens auth.RefreshTokenRepository, tokens *jwt.Manager, refreshTokenTTL time.Duration) *AuthService {
+	return &AuthService{
+		UserService:     userService,
+		RefreshTokens:   refreshTokens,
+		Tokens:          tokens,
+		RefreshTokenTTL: refreshTokenTTL,
+	}
+}
+
+func (service *AuthService) Login(ctx context.Context, credentials auth.Credentials) (auth.Token, *errors.CustomError) {
+	if credentials.Login == "" || credentials.Password == "" {
+		return auth.Token{}, errors.NewCustomError(
+			errors.Validation,
+			"EMPTY_FIELDS",
+			"You must provide a login and a password.",
+			"The login can be either the username or the email of the user.",
+			time.Now(),
+		)
+	}
+
+	// Looking for the user by email or by username
+	var u user.User
+	var err_get *errors.CustomError
+	if strings.Contains(credentials.Login, "@") {
+		u, err_get = service.UserService.GetUserByEmail(ctx, credentials.Login)
+	} else {
+		u, err_get = service.UserService.GetUserByUsername(ctx, credentials.Login)
+	}
+
+	// The same error is returned whether the user does not exist or the password is wrong
+	if err_get != nil || !u.PasswordMatch(credentials.Password) {
+		return auth.Token{}, invalidCredentialsError()
+	}
+
+	// Starting a new token family for this session
+	familyId, _, err := auth.NewOpaqueToken()
+	if err != nil {
+		return auth.Token{}, errorGeneratingToken(err)
+	}
+
+	refreshToken, refreshTokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return auth.Token{}, errorGeneratingToken(err)
+	}
+
+	now := time.Now()
+	stored := auth.RefreshToken{
+		UserID:    u.ID,
+		FamilyID:  familyId,
+		TokenHash: refreshTokenHash,
+		ExpiresAt: now.Add(service.RefreshTokenTTL),
+		CreatedAt: now,
+	}
+	if err := service.RefreshTokens.Create(ctx, &stored); err != nil {
+		return auth.Token{}, errorGeneratingToken(err)
+	}
+
+	return service.newToken(u.ID, refreshToken, stored.ExpiresAt)
+}
+
+func (service *AuthService) Refresh(ctx context.Context, refreshToken string) (auth.Token, *errors.CustomError) {
+	stored, err := service.RefreshTokens.GetByHash(ctx, auth.HashToken(refreshToken))
+	if err != nil {
+		return auth.Token{}, invalidRefreshTokenError("The refresh token does not exist.")
+	}
+
+	// A revoked token being presented again means it was stolen or replayed, so the whole session is revoked
+	if stored.IsRevoked() {
+		return auth.Token{}, service.revokeReusedFamily(ctx, stored)
+	}
+
+	now := time.Now()
+	if stored.IsExpired(now) {
+		return auth.Token{}, invalidRefreshTokenError("The refresh token has expired.")
+	}
+
+	newRefreshToken, newRefreshTokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return auth.Token{}, errorGeneratingToken(err)
+	}
+
+	rotated := auth.RefreshToken{
+		UserID:    stored.UserID,
+		FamilyID:  stored.FamilyID,
+		TokenHash: newRefreshTokenHash,
+		ExpiresAt: now.Add(service.RefreshTokenTTL),
+		CreatedAt: now,
+	}
+	err = service.RefreshTokens.Rotate(ctx, stored.ID, &rotated)
+	if errors.Is(err, auth.ErrRefreshTokenRevoked) {
+		return auth.Token{}, service.revokeReusedFamily(ctx, stored)
+	}
+	if err != nil {
+		return auth.Token{}, errorGeneratingToken(err)
+	}
+
+	return service.newToken(stored.UserID, newRefreshToken, rotated.ExpiresAt)
+}
+
+func (service *AuthService) Logout(ctx context.Context, refreshToken string) *errors.CustomError {
+	stored, err := service.RefreshTokens.GetByHash(ctx, auth.HashToken(refreshToken))
+	if err != nil {
+		return invalidRefreshTokenError("The refresh token does not exist.")
+	}
+
+	if err := service.RefreshTokens.RevokeFamily(ctx, stored.FamilyID); err != nil {
+		return errors.NewCustomError(
+			errors.KindOf(err),
+			"ERROR_REVOKING_TOKEN",
+			"An error occurred while closing the session.",
+			err.Error(),
+			time.Now(),
+		)
+	}
+	return nil
+}
+
+func (service *AuthService) Authenticate(ctx context.Context, accessToken string) (auth.Principal, *errors.CustomError) {
+	claims, err := service.Tokens.Parse(accessToken)
+	if err != nil {
+		return auth.Principal{}, errors.NewCustomError(
+			errors.Unauthorized,
+			"INVALID_TOKEN",
+			"The access token is not valid.",
+			err.Error(),
+			time.Now(),
+		)
+	}
+
+	userId, err := claims.UserID()
+	if err != nil {
+		return auth.Principal{}, errors.NewCustomError(
+			errors.Unauthorized,
+			"INVALID_TOKEN",
+			"The access token is not valid.",
+			err.Error(),
+			time.Now(),
+		)
+	}
+
+	// The role is loaded on every request so that role changes take effect immediately
+	u, err_get := service.UserService.GetUserById(ctx, userId)
+	if err_get != nil {
+		if err_get.Kind != errors.NotFound {
+			return auth.Principal{}, err_get
+		}
+		return auth.Principal{}, errors.NewCustomError(
+			errors.Unauthorized,
+			"INVALID_TOKEN",
+			"The access token is not valid.",
+			"The user the token was issued to does not exist anymore.",
+			time.Now(),
+		)
+	}
+
+	return auth.Principal{
+		UserID: u.ID,
+		Role:   u.Role,
+	}, nil
+}
+
+func (service *AuthService) newToken(userId uint, refreshToken string, refreshExpiresAt time.Time) (auth.Token, *errors.CustomError) {
+	accessToken, expiresAt, err := service.Tokens.Generate(userId)
+	if err != nil {
+		return auth.Token{}, errorGeneratingToken(err)
+	}
+
+	return auth.Token{
+		AccessToken:      accessToken,
+		TokenType:        "Bearer",
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+	}, nil
+}
+
+func (service *AuthService) revokeReusedFamily(ctx context.Context, reused auth.RefreshToken) *errors.CustomError {
+	if err := service.RefreshTokens.RevokeFamily(ctx, reused.FamilyID); err != nil {
+		return errors.NewCustomError(
+			errors.KindOf(err),
+			"ERROR_REVOKING_TOKEN",
+			"An error occurred while revoking the session.",
+			err.Error(),
+			time.Now(),
+		)
+	}
+	return errors.NewCustomError(
+		errors.Unauthorized,
+		"REFRESH_TOKEN_REUSED",
+		"The refresh token was already used.",
+		"The session has been revoked for security reasons. Log in again.",
+		time.Now(),
+	)
+}
+
+func errorGeneratingToken(err error) *errors.CustomError {
+	return errors.NewCustomError(
+		errors.Internal,
+		"ERROR_GENERATING_TOKEN",
+		"An error occurred while generating the session tokens.",
+		err.Error(),
+		time.Now(),
+	)
+}
+
+func invalidRefreshTokenError(details string) *errors.CustomError {
+	return errors.NewCustomError(
+		errors.Unauthorized,
+		"INVALID_REFRESH_TOKEN",
+		"The refresh token is not valid.",
+		details,
+		time.Now(),
+	)
+}
+
+func invalidCredentialsError() *errors.CustomError {
+	return errors.NewCustomError(
+		errors.Unauthorized,
+		"INVALID_CREDENTIALS",
+		"The login or the password are incorrect.",
+		"Check your credentials and try again.",
+		time.Now(),
+	)
+}
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/auth"
+	"github.com/cortzero/go-postgres-blog/internal/model/comment"
+	"github.com/cortzero/go-postgres-blog/internal/model/post"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+	"github.com/cortzero/go-postgres-blog/internal/service/policy"
+)
+
+// CommentService is a service layer component that manages the threaded comments of posts
+type CommentService struct {
+	Repository     comment.Repository
+	PostRepository post.Repository
+}
+
+func NewCommentService(repository comment.Repository, postRepository post.Repository) *CommentService {
+	return &CommentService{
+		Repository:     repository,
+		PostRepository: postRepository,
+	}
+}
+
+func (service *CommentService) CreateComment(ctx context.Context, postId uint, c *comment.Comment) *errors.CustomError {
+	principal, err_actor := actingPrincipal(ctx)
+	if err_actor != nil {
+		return err_actor
+	}
+
+	if err := validateCommentBody(c.Body); err != nil {
+		return err
+	}
+
+	// Only published posts can be commented
+	existingPost, err_post := service.getVisiblePost(ctx, postId)
+	if err_post != nil {
+		return err_post
+	}
+	if existingPost.Status != post.StatusPublished {
+		return errors.NewCustomError(
+			errors.Conflict,
+			"POST_NOT_PUBLISHED",
+			"You can't comment a post that is not published.",
+			fmt.Sprintf("The post with id '%d' is %s.", postId, existingPost.Status),
+			time.Now(),
+		)
+	}
+
+	// Replies must belong to the same post and respect the maximum depth
+	c.Depth = 0
+	if c.ParentID != nil {
+		parent, err := service.getPostComment(ctx, postId, *c.ParentID)
+		if err != nil {
+			return err
+		}
+		if parent.Deleted {
+			return errors.NewCustomError(
+				errors.Validation,
+				"INVALID_PARENT",
+				"You can't reply to a deleted comment.",
+				fmt.Sprintf("The comment with id '%d' was deleted.", parent.ID),
+				time.Now(),
+			)
+		}
+		if parent.Depth+1 > comment.MaxDepth {
+			return errors.NewCustomError(
+				errors.Validation,
+				"MAX_DEPTH_EXCEEDED",
+				"The thread is too deep to reply to this comment.",
+				fmt.Sprintf("Replies can be nested at most %d levels.", comment.MaxDepth),
+				time.Now(),
+			)
+		}
+		c.Depth = parent.Depth + 1
+	}
+
+	c.PostID = postId
+	c.UserID = principal.UserID
+	c.CreatedAt = time.Now()
+
+	err := service.Repository.Create(ctx, c)
+	if err != nil {
+		return errors.NewCustomError(
+			errors.KindOf(err),
+			"ERROR_CREATING_COMMENT",
+			"An error occurred while creating the comment.",
+			err.Error(),
+			time.Now(),
+		)
+	}
+	return nil
+}
+
+func (service *CommentService) UpdateComment(ctx context.Context, postId uint, id uint, c *comment.Comment) *errors.CustomError {
+	principal, err_actor := actingPrincipal(ctx)
+	if err_actor != nil {
+		return err_actor
+	}
+
+	if err := validateCommentBody(c.Body); err != nil {
+		return err
+	}
+
+	existingComment, err := service.getPostComment(ctx, postId, id)
+	if err != nil {
+		return err
+	}
+	if existingComment.Deleted {
+		return commentNotFoundError(id, "The comment was deleted.")
+	}
+
+	if !policy.CanEditComment(principal, existingComment) {
+		return forbiddenError(
+			"You are not allowed to edit this comment.",
+			"Only the author of a comment can edit it.",
+		)
+	}
+
+	existingComment.Body = c.Body
+	existingComment.UpdatedAt = time.Now()
+	err_update := service.Repository.Update(ctx, id, existingComment)
+	if err_update != nil {
+		return errors.NewCustomError(
+			errors.KindOf(err_update),
+			"ERROR_UPDATING_COMMENT",
+			"An error occurred while updating the comment.",
+			err_update.Error(),
+			time.Now(),
+		)
+	}
+
+	*c = existingComment
+	return nil
+}
+
+func (service *CommentService) DeleteComment(ctx context.Context, postId uint, id uint) *errors.CustomError {
+	principal, err_actor := actingPrincipal(ctx)
+	if err_actor != nil {
+		return err_actor
+	}
+
+	existingComment, err := service.getPostComment(ctx, postId, id)
+	if err != nil {
+		return err
+	}
+	if existingComment.Deleted {
+		return commentNotFoundError(id, "The comment was already deleted.")
+	}
+
+	if !policy.CanDeleteComment(principal, existingComment) {
+		return forbiddenError(
+			"You are not allowed to delete this comment.",
+			"Only the author of a comment or a moderator can delete it.",
+		)
+	}
+
+	err_delete := service.Repository.SoftDelete(ctx, id)
+	if err_delete != nil {
+		return errors.NewCustomError(
+			errors.KindOf(err_delete),
+			"ERROR_DELETING_COMMENT",
+			"An error occurred while deleting the comment.",
+			err_delete.Error(),
+			time.Now(),
+		)
+	}
+	return nil
+}
+
+// GetCommentTree returns the top-level comments of the post with their replies nested down to maxDepth.
+// Deleted comments are only kept when they still have replies.
+func (service *CommentService) GetCommentTree(ctx context.Context, postId uint, maxDepth int) ([]*comment.Comment, *errors.CustomError) {
+	if maxDepth < 0 || maxDepth > comment.MaxDepth {
+		maxDepth = comment.MaxDepth
+	}
+
+	if _, err := service.getVisiblePost(ctx, postId); err != nil {
+		return nil, err
+	}
+
+	comments, err := service.Repository.GetByPost(ctx, postId, maxDepth)
+	if err != nil {
+		return nil, errors.NewCustomError(
+			errors.KindOf(err),
+			"ERROR_GETTING_COMMENTS",
+			"An error occurred while getting the comments of the post.",
+			err.Error(),
+			time.Now(),
+		)
+	}
+	return buildCommentTree(comments), nil
+}
+
+// getVisiblePost returns the post if the user making the request can see it. Posts that are not
+// published are reported as not found to those who can't edit them.
+func (service *CommentService) getVisiblePost(ctx context.Context, postId uint) (post.Post, *errors.CustomError) {
+	existingPost, err := service.PostRepository.GetById(ctx, postId)
+	if err != nil {
+		if errors.KindOf(err) != errors.NotFound {
+			return post.Post{}, errors.NewCustomError(
+				errors.Internal,
+				"ERROR_GETTING_POST",
+				"An error occurred while getting the post.",
+				err.Error(),
+				time.Now(),
+			)
+		}
+		return post.Post{}, postNotFoundError(postId, err.Error())
+	}
+	principal, _ := auth.PrincipalFromContext(ctx)
+	if !policy.CanViewPost(principal, existingPost) {
+		return post.Post{}, postNotFoundError(postId, "The post is not published.")
+	}
+	return existingPost, nil
+}
+
+func (service *CommentService) getPostComment(ctx context.Context, postId uint, id uint) (comment.Comment, *errors.CustomError) {
+	c, err := service.Repository.GetById(ctx, id)
+	if err != nil {
+		if errors.KindOf(err) != errors.NotFound {
+			return comment.Comment{}, errors.NewCustomError(
+				errors.Internal,
+				"ERROR_GETTING_COMMENT",
+				"An error occurred while getting the comment.",
+				err.Error(),
+				time.Now(),
+			)
+		}
+		return comment.Comment{}, commentNotFoundError(id, err.Error())
+	}
+	if c.PostID != postId {
+		return comment.Comment{}, commentNotFoundError(id, fmt.Sprintf("The comment does not belong to the post with id '%d'.", postId))
+	}
+	return c, nil
+}
+
+// buildCommentTree nests the comments under their parents. The comments must be sorted by depth.
+func buildCommentTree(comments []comment.Comment) []*comment.Comment {
+	nodes := make(map[uint]*comment.Comment, len(comments))
+	roots := []*comment.Comment{}
+	for i := range comments {
+		node := &comments[i]
+		nodes[node.ID] = node
+		if node.ParentID == nil {
+			roots = append(roots, node)
+			continue
+		}
+		if parent, ok := nodes[*node.ParentID]; ok {
+			parent.Replies = append(parent.Replies, node)
+		}
+	}
+	return pruneDeletedLeaves(roots)
+}
+
+func pruneDeletedLeaves(nodes []*comment.Comment) []*comment.Comment {
+	kept := nodes[:0]
+	for _, node := range nodes {
+		node.Replies = pruneDeletedLeaves(node.Replies)
+		if node.Deleted && len(node.Replies) == 0 {
+			continue
+		}
+		kept = append(kept, node)
+	}
+	return kept
+}
+
+func validateCommentBody(body string) *errors.CustomError {
+	if strings.TrimSpace(body) == "" {
+		return errors.NewCustomError(
+			errors.Validation,
+			"EMPTY_FIELDS",
+			"The comment can't be empty.",
+			"Fill the 'body' field of the comment.",
+			time.Now(),
+		)
+	}
+	if utf8.RuneCountInString(body) > comment.MaxBodyLength {
+		return errors.NewCustomError(
+			errors.Validation,
+			"COMMENT_TOO_LONG",
+			"The comment is too long.",
+			fmt.Sprintf("A comment can have at most %d characters.", comment.MaxBodyLength),
+			time.Now(),
+		)
+	}
+	return nil
+}
+
+func postNotFoundError(id uint, details string) *errors.CustomError {
+	return errors.NewCustomError(
+		errors.NotFound,
+		"RESOURCE_NOT_FOUND",
+		fmt.Sprintf("There is not a post with id '%d'.", id),
+		details,
+		time.Now(),
+	)
+}
+
+func commentNotFoundError(id uint, details string) *errors.CustomError {
+	return errors.NewCustomError(
+		errors.NotFound,
+		"RESOURCE_NOT_FOUND",
+		fmt.Sprintf("There is not a comment with id '%d'.", id),
+		details,
+		time.Now(),
+	)
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/export"
+)
+
+// buildExportArchive writes the contents into a ZIP archive. Every kind of data has a JSON file meant to
+// be read by programs, and the profile, the posts and the comments have Markdown files meant to be read
+// by people.
+func buildExportArchive(contents export.Contents, generatedAt time.Time) ([]byte, error) {
+	files := []exportFile{
+		{"README.md", exportReadme(contents, generatedAt)},
+		{"profile.json", contents.User},
+		{"profile.md", exportProfileMarkdown(contents)},
+		{"posts.json", contents.Posts},
+		{"comments.json", contents.Comments},
+		{"comments.md", exportCommentsMarkdown(contents)},
+		{"sessions.json", contents.Sessions},
+		{"audit.json", contents.Audit},
+	}
+	for _, p := range contents.Posts {
+		files = append(files, exportFile{fmt.Sprintf("posts/%d-%s.md", p.ID, p.Slug), exportPostMarkdown(p)})
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, file := range files {
+		content, ok := file.content.([]byte)
+		if !ok {
+			var err error
+			if content, err = json.MarshalIndent(file.content, "", "  "); err != nil {
+				return nil, fmt.Errorf("writing %s: %w", file.name, err)
+			}
+		}
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: generatedAt})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(content); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// exportFile is a file of the archive. A content that is not already []byte is written as JSON.
+type exportFile struct {
+	name    string
+	content any
+}
+
+func exportTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func exportReadme(contents export.Contents, generatedAt time.Time) []byte {
+	var md strings.Builder
+	fmt.Fprintf(&md, "# Personal data of %s\n\n", contents.User.Username)
+	fmt.Fprintf(&md, "Everything stored about the user with id %d on %s.\n\n", contents.User.ID, exportTime(generatedAt))
+	fmt.Fprintf(&md, "- `profile.json`, `profile.md`: the account. The password is only stored as a hash, which is not included.\n")
+	fmt.Fprintf(&md, "- `posts.json`, `posts/`: the %d posts, including the drafts and the ones in the trash.\n", len(contents.Posts))
+	fmt.Fprintf(&md, "- `comments.json`, `comments.md`: the %d comments, including the deleted ones.\n", len(contents.Comments))
+	fmt.Fprintf(&md, "- `sessions.json`: the %d logins. The tokens themselves are not included.\n", len(contents.Sessions))
+	fmt.Fprintf(&md, "- `audit.json`: the %d revisions of posts made by the user, which are the changes recorded along with who made them.\n", len(contents.Audit))
+	return []byte(md.String())
+}
+
+func exportProfileMarkdown(contents export.Contents) []byte {
+	u := contents.User
+	var md strings.Builder
+	fmt.Fprintf(&md, "# %s %s\n\n", u.FirstName, u.LastName)
+	fmt.Fprintf(&md, "- Username: %s\n", u.Username)
+	fmt.Fprintf(&md, "- Email: %s\n", u.Email)
+	if u.Picture != "" {
+		fmt.Fprintf(&md, "- Picture: %s\n", u.Picture)
+	}
+	fmt.Fprintf(&md, "- Role: %s\n", u.Role)
+	fmt.Fprintf(&md, "- Member since: %s\n", exportTime(u.CreatedAt))
+	return []byte(md.String())
+}
+
+func exportCommentsMarkdown(contents export.Contents) []byte {
+	var md strings.Builder
+	md.WriteString("# Comments\n")
+	for _, c := range contents.Comments {
+		fmt.Fprintf(&md, "\n## Comment %d on post %d\n\n", c.ID, c.PostID)
+		fmt.Fprintf(&md, "- Written: %s\n", exportTime(c.CreatedAt))
+		if c.DeletedAt != nil {
+			fmt.Fprintf(&md, "- Deleted: %s\n", exportTime(*c.DeletedAt))
+		}
+		fmt.Fprintf(&md, "\n%s\n", c.Body)
+	}
+	return []byte(md.String())
+}
+
+func exportPostMarkdown(p export.Post) []byte {
+	var md strings.Builder
+	fmt.Fprintf(&md, "# %s\n\n", p.Title)
+	fmt.Fprintf(&md, "- Status: %s\n", p.Status)
+	fmt.Fprintf(&md, "- Format: %s\n", p.BodyFormat)
+	if len(p.Tags) > 0 {
+		fmt.Fprintf(&md, "- Tags: %s\n", strings.Join(p.Tags, ", "))
+	}
+	fmt.Fprintf(&md, "- Written: %s\n", exportTime(p.CreatedAt))
+	if p.PublishedAt != nil {
+		fmt.Fprintf(&md, "- Published: %s\n", exportTime(*p.PublishedAt))
+	}
+	if p.DeletedAt != nil {
+		fmt.Fprintf(&md, "- Deleted: %s\n", exportTime(*p.DeletedAt))
+	}
+	fmt.Fprintf(&md, "\n%s\n", p.Body)
+	return []byte(md.String())
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/export"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+)
+
+// exportBatchSize is the number of pending exports produced each time the background job runs
+const exportBatchSize = 10
+
+// ExportService is a service layer component that exports everything stored about a user. Small accounts
+// are exported while the request waits, and larger ones in the background by ProcessExports.
+type ExportService struct {
+	Repository export.Repository
+	Expiry     time.Duration
+}
+
+func NewExportService(repository export.Repository, expiry time.Duration) *ExportService {
+	return &ExportService{
+		Repository: repository,
+		Expiry:     expiry,
+	}
+}
+
+// ExportUser returns the archive of the personal data of the user when the account is small enough to be
+// exported right away. Otherwise it returns a pending export, which can be downloaded once it is ready.
+func (service *ExportService) ExportUser(ctx context.Context, userId uint) ([]byte, export.Export, *errors.CustomError) {
+	// Users can only export their own account unless they are admins
+	if err := checkCanManageUser(ctx, userId, "You are not allowed to export this user."); err != nil {
+		return nil, export.Export{}, err
+	}
+
+	// Users in the trash can still export their data, so the count is what tells whether the user exists
+	count, err := service.Repository.Count(ctx, userId)
+	if errors.Is(err, errors.ErrNotFound) {
+		return nil, export.Export{}, errors.NewCustomError(
+			errors.NotFound,
+			"RESOURCE_NOT_FOUND",
+			fmt.Sprintf("There is not a user with id '%d'.", userId),
+			err.Error(),
+			time.Now(),
+		)
+	}
+	if err != nil {
+		return nil, export.Export{}, exportError(err)
+	}
+
+	if count <= export.MaxSyncItems {
+		archive, err := service.buildArchive(ctx, userId)
+		if err != nil {
+			return nil, export.Export{}, exportError(err)
+		}
+		return archive, export.Export{}, nil
+	}
+
+	pending, err := service.Repository.Create(ctx, userId)
+	if err != nil {
+		return nil, export.Export{}, exportError(err)
+	}
+	return nil, pending, nil
+}
+
+// GetExport returns the export of the user, which tells whether its archive is ready.
+func (service *ExportService) GetExport(ctx context.Context, userId uint, id uint) (export.Export, *errors.CustomError) {
+	if err := checkCanManageUser(ctx, userId, "You are not allowed to export this user."); err != nil {
+		return export.Export{}, err
+	}
+
+	e, err := service.Repository.GetById(ctx, userId, id)
+	if err != nil {
+		return export.Export{}, errors.NewCustomError(
+			errors.KindOf(err),
+			"RESOURCE_NOT_FOUND",
+			fmt.Sprintf("There is not an export with id '%d' for the user '%d'.", id, userId),
+			err.Error(),
+			time.Now(),
+		)
+	}
+	return e, nil
+}
+
+// GetArchive returns the archive of the export of the user once it is ready.
+func (service *ExportService) GetArchive(ctx context.Context, userId uint, id uint) ([]byte, *errors.CustomError) {
+	e, err_get := service.GetExport(ctx, userId, id)
+	if err_get != nil {
+		return nil, err_get
+	}
+
+	if e.Status != export.StatusReady {
+		return nil, errors.NewCustomError(
+			errors.Conflict,
+			"EXPORT_NOT_READY",
+			fmt.Sprintf("The export with id '%d' is not ready.", id),
+			fmt.Sprintf("The export is %s.", e.Status),
+			time.Now(),
+		)
+	}
+
+	archive, err := service.Repository.GetArchive(ctx, userId, id)
+	if err != nil {
+		return nil, exportError(err)
+	}
+	return archive, nil
+}
+
+// ProcessExports removes the expired exports and produces the pending ones claimed by this instance,
+// returning how many were produced. It is run by a background job, so it does not check the user making
+// the request. The exports that can't be produced are marked as failed along with the reason, which is
+// what their owners see.
+func (service *ExportService) ProcessExports(ctx context.Context) (int, *errors.CustomError) {
+	if _, err := service.Repository.DeleteExpired(ctx, time.Now()); err != nil {
+		return 0, exportError(err)
+	}
+
+	claimed, err := service.Repository.ClaimPending(ctx, exportBatchSize)
+	if err != nil {
+		return 0, exportError(err)
+	}
+
+	produced := 0
+	for _, e := range claimed {
+		expiresAt := time.Now().Add(service.Expiry)
+		archive, err := service.buildArchive(ctx, e.UserID)
+		if err != nil {
+			err = service.Repository.Fail(ctx, e.ID, err.Error(), expiresAt)
+		} else if err = service.Repository.Complete(ctx, e.ID, archive, expiresAt); err == nil {
+			produced++
+		}
+		// The claim may have expired and another instance finished the export in the meantime
+		if err != nil && !errors.Is(err, errors.ErrNotFound) {
+			return produced, exportError(err)
+		}
+	}
+	return produced, nil
+}
+
+func (service *ExportService) buildArchive(ctx context.Context, userId uint) ([]byte, error) {
+	contents, err := service.Repository.Collect(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return buildExportArchive(contents, time.Now())
+}
+
+func exportError(err error) *errors.CustomError {
+	return errors.NewCustomError(
+		errors.KindOf(err),
+		"ERROR_EXPORTING_USER",
+		"An error occurred while exporting the user.",
+		err.Error(),
+		time.Now(),
+	)
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/post"
+	"github.com/cortzero/go-postgres-blog/internal/service/diff"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+	"github.com/cortzero/go-postgres-blog/internal/service/policy"
+)
+
+// GetPostRevisions returns the history of the post, newest first. Only those who can edit the post can see it.
+func (service *PostService) GetPostRevisions(ctx context.Context, id uint) ([]post.Revision, *errors.CustomError) {
+	if _, err := service.getEditablePost(ctx, id); err != nil {
+		return nil, err
+	}
+
+	revisions, err := service.Repository.GetRevisions(ctx, id)
+	if err != nil {
+		return nil, errors.NewCustomError(
+			errors.KindOf(err),
+			"ERROR_GETTING_REVISIONS",
+			"An error occurred while getting the revisions of the post.",
+			err.Error(),
+			time.Now(),
+		)
+	}
+	return revisions, nil
+}
+
+// DiffPostRevisions compares the title and the body of two revisions of the post line by line.
+func (service *PostService) DiffPostRevisions(ctx context.Context, id uint, from int, to int) (post.RevisionDiff, *errors.CustomError) {
+	if _, err := service.getEditablePost(ctx, id); err != nil {
+		return post.RevisionDiff{}, err
+	}
+
+	fromRevision, err_from := service.getRevision(ctx, id, from)
+	if err_from != nil {
+		return post.RevisionDiff{}, err_from
+	}
+	toRevision, err_to := service.getRevision(ctx, id, to)
+	if err_to != nil {
+		return post.RevisionDiff{}, err_to
+	}
+
+	return post.RevisionDiff{
+		From:  from,
+		To:    to,
+		Title: diffLines(fromRevision.Title, toRevision.Title),
+		Body:  diffLines(fromRevision.Body, toRevision.Body),
+	}, nil
+}
+
+// RestorePostRevision brings back the title, the body and the format of the body of an older revision. The restore is an
+// update like any other, so it appends a new revision and the history is never rewritten.
+func (service *PostService) RestorePostRevision(ctx context.Context, id uint, number int) (post.Post, *errors.CustomError) {
+	principal, err_actor := actingPrincipal(ctx)
+	if err_actor != nil {
+		return post.Post{}, err_actor
+	}
+
+	existingPost, err_post := service.getEditablePost(ctx, id)
+	if err_post != nil {
+		return post.Post{}, err_post
+	}
+
+	revision, err_revision := service.getRevision(ctx, id, number)
+	if err_revision != nil {
+		return post.Post{}, err_revision
+	}
+
+	// The tags are not versioned, so they are kept as they are
+	existingPost.Title = revision.Title
+	existingPost.Body = revision.Body
+	existingPost.BodyFormat = revision.BodyFormat
+	if err := renderPostBody(&existingPost); err != nil {
+		return post.Post{}, err
+	}
+	existingPost.UpdatedAt = time.Now()
+	existingPost.Tags = nil
+
+	error_update := service.Repository.Update(ctx, id, existingPost, principal.UserID)
+	if error_update != nil {
+		return post.Post{}, errors.NewCustomError(
+			errors.KindOf(error_update),
+			"ERROR_UPDATING_POST",
+			"An error occurred while restoring the revision of the post.",
+			error_update.Error(),
+			time.Now(),
+		)
+	}
+
+	// Reloading the post, whose slug changes along with the title
+	return service.getPost(ctx, id)
+}
+
+// getEditablePost returns the post if the user making the request can edit it.
+func (service *PostService) getEditablePost(ctx context.Context, id uint) (post.Post, *errors.CustomError) {
+	principal, err_actor := actingPrincipal(ctx)
+	if err_actor != nil {
+		return post.Post{}, err_actor
+	}
+
+	existingPost, err := service.getPost(ctx, id)
+	if err != nil {
+		return post.Post{}, err
+	}
+
+	if !policy.CanEditPost(principal, existingPost) {
+		return post.Post{}, forbiddenError(
+			"You are not allowed to access the revisions of this post.",
+			fmt.Sprintf("The post with id '%d' belongs to another user and your role does not allow it.", id),
+		)
+	}
+	return existingPost, nil
+}
+
+func (service *PostService) getRevision(ctx context.Context, id uint, number int) (post.Revision, *errors.CustomError) {
+	revision, err := service.Repository.GetRevision(ctx, id, number)
+	if err != nil {
+		return post.Revision{}, errors.NewCustomError(
+			errors.KindOf(err),
+			"RESOURCE_NOT_FOUND",
+			fmt.Sprintf("The post with id '%d' does not have a revision %d.", id, number),
+			err.Error(),
+			time.Now(),
+		)
+	}
+	return revision, nil
+}
+
+// diffLines compares the texts line by line.
+func diffLines(from string, to string) []post.DiffLine {
+	lines := diff.Lines(from, to)
+	diffLines := make([]post.DiffLine, 0, len(lines))
+	for _, line := range lines {
+		diffLines = append(diffLines, post.DiffLine{Op: string(line.Op), Text: line.Text})
+	}
+	return diffLines
+}
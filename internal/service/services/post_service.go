@@ -2,10 +2,16 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/cortzero/go-postgres-blog/internal/model/auth"
+	"github.com/cortzero/go-postgres-blog/internal/model/page"
 	"github.com/cortzero/go-postgres-blog/internal/model/post"
 	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+	"github.com/cortzero/go-postgres-blog/internal/service/policy"
+	"github.com/cortzero/go-postgres-blog/internal/service/render"
 )
 
 type PostService struct {
@@ -18,14 +24,61 @@ func NewPostService(repository post.Repository) *PostService {
 	}
 }
 
-func (service *PostService) CreatePost(ctx context.Context, post *post.Post) *errors.CustomError {
+func (service *PostService) CreatePost(ctx context.Context, newPost *post.Post) *errors.CustomError {
+	// The author of the post is always the authenticated user
+	principal, err_actor := actingPrincipal(ctx)
+	if err_actor != nil {
+		return err_actor
+	}
+	if !policy.CanCreatePost(principal) {
+		return forbiddenError(
+			"You are not allowed to create posts.",
+			"Your role does not grant the permission to create posts.",
+		)
+	}
+	newPost.UserID = principal.UserID
+
+	if err := validatePost(newPost); err != nil {
+		return err
+	}
+
+	tags, err_tags := normalizeTags(newPost.Tags)
+	if err_tags != nil {
+		return err_tags
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	newPost.Tags = tags
+
+	// Posts start as drafts unless they are published or scheduled right away,
+	// in which case published_at is the time of the publication
+	status := newPost.Status
+	newPost.Status = post.StatusDraft
+	if status != "" && status != post.StatusDraft {
+		publishAt := newPost.PublishedAt
+		newPost.PublishedAt = nil
+		if err := applyStatusChange(principal, newPost, post.StatusChange{Status: status, PublishAt: publishAt}); err != nil {
+			return err
+		}
+	}
+
+	// Bodies without a format are plain text, as they were before formats existed
+	if newPost.BodyFormat == "" {
+		newPost.BodyFormat = post.FormatPlain
+	}
+	if err := renderPostBody(newPost); err != nil {
+		return err
+	}
+
 	// Set the timestamp of creation of the post
-	post.CreatedAt = time.Now()
+	newPost.CreatedAt = time.Now()
 
 	// Save post
-	err := service.Repository.Create(ctx, post)
+	err := service.Repository.Create(ctx, newPost)
 	if err != nil {
 		return errors.NewCustomError(
+			errors.KindOf(err),
 			"ERROR_CREATING_POST",
 			"An error occurred while creating the post.",
 			err.Error(),
@@ -35,22 +88,58 @@ func (service *PostService) CreatePost(ctx context.Context, post *post.Post) *er
 	return nil
 }
 
-func (service *PostService) UpdatePost(ctx context.Context, id uint, post *post.Post) *errors.CustomError {
+// UpdatePost changes the content of the post. When updatedPost.Version is set the post is only changed
+// while it is at that version.
+func (service *PostService) UpdatePost(ctx context.Context, id uint, updatedPost *post.Post) (post.Post, *errors.CustomError) {
+	principal, err_actor := actingPrincipal(ctx)
+	if err_actor != nil {
+		return post.Post{}, err_actor
+	}
+
 	// Check if the post exists
-	existingPost, error_existing := service.GetPostById(ctx, id)
+	existingPost, error_existing := service.getPost(ctx, id)
 	if error_existing != nil {
-		return error_existing
+		return post.Post{}, error_existing
+	}
+
+	if !policy.CanEditPost(principal, existingPost) {
+		return post.Post{}, forbiddenError(
+			"You are not allowed to edit this post.",
+			fmt.Sprintf("The post with id '%d' belongs to another user and your role does not allow it.", id),
+		)
+	}
+
+	if err := checkVersion("post", id, updatedPost.Version, existingPost.Version); err != nil {
+		return post.Post{}, err
+	}
+
+	if err := validatePost(updatedPost); err != nil {
+		return post.Post{}, err
+	}
+
+	// The tags are only replaced when they are sent, a nil slice keeps the current ones
+	tags, err_tags := normalizeTags(updatedPost.Tags)
+	if err_tags != nil {
+		return post.Post{}, err_tags
 	}
 
 	// Updating the existing post
-	existingPost.Title = post.Title
-	existingPost.Body = post.Body
+	existingPost.Title = updatedPost.Title
+	existingPost.Body = updatedPost.Body
+	existingPost.Tags = tags
+	if updatedPost.BodyFormat != "" {
+		existingPost.BodyFormat = updatedPost.BodyFormat
+	}
+	if err := renderPostBody(&existingPost); err != nil {
+		return post.Post{}, err
+	}
 	existingPost.UpdatedAt = time.Now()
 
 	// Update post
-	error_update := service.Repository.Update(ctx, id, existingPost)
+	error_update := service.Repository.Update(ctx, id, existingPost, principal.UserID)
 	if error_update != nil {
-		return errors.NewCustomError(
+		return post.Post{}, errors.NewCustomError(
+			errors.KindOf(error_update),
 			"ERROR_UPDATING_POST",
 			"An error occurred while updating the post.",
 			error_update.Error(),
@@ -58,20 +147,39 @@ func (service *PostService) UpdatePost(ctx context.Context, id uint, post *post.
 		)
 	}
 
-	return nil
+	// Reloading the post, whose slug changes along with the title
+	return service.getPost(ctx, id)
 }
 
-func (service *PostService) DeletePost(ctx context.Context, id uint) *errors.CustomError {
+// DeletePost moves the post to the trash. When the version is not 0 the post is only deleted while it is at that version.
+func (service *PostService) DeletePost(ctx context.Context, id uint, version int) *errors.CustomError {
+	principal, err_actor := actingPrincipal(ctx)
+	if err_actor != nil {
+		return err_actor
+	}
+
 	// Check if the post exists
-	_, error_get := service.GetPostById(ctx, id)
+	existingPost, error_get := service.getPost(ctx, id)
 	if error_get != nil {
 		return error_get
 	}
 
+	if !policy.CanDeletePost(principal, existingPost) {
+		return forbiddenError(
+			"You are not allowed to delete this post.",
+			fmt.Sprintf("The post with id '%d' belongs to another user and your role does not allow it.", id),
+		)
+	}
+
+	if err := checkVersion("post", id, version, existingPost.Version); err != nil {
+		return err
+	}
+
 	// Deleting the post
-	error_deleting := service.Repository.Delete(ctx, id)
+	error_deleting := service.Repository.Delete(ctx, id, existingPost.Version)
 	if error_deleting != nil {
 		return errors.NewCustomError(
+			errors.KindOf(error_deleting),
 			"ERROR_DELETING_POST",
 			"An error occurred while deleting the post.",
 			error_deleting.Error(),
@@ -81,10 +189,133 @@ func (service *PostService) DeletePost(ctx context.Context, id uint) *errors.Cus
 	return nil
 }
 
-func (service *PostService) GetAllPosts(ctx context.Context) ([]post.Post, *errors.CustomError) {
-	posts, err := service.Repository.GetAll(ctx)
+// RestorePost takes the post out of the trash, which can't be done while its author is deleted.
+func (service *PostService) RestorePost(ctx context.Context, id uint) (post.Post, *errors.CustomError) {
+	principal, err_actor := actingPrincipal(ctx)
+	if err_actor != nil {
+		return post.Post{}, err_actor
+	}
+	if !policy.CanManageTrash(principal) {
+		return post.Post{}, forbiddenError(
+			"You are not allowed to restore posts.",
+			"Only admins can restore deleted posts.",
+		)
+	}
+
+	error_restore := service.Repository.Restore(ctx, id)
+	if errors.Is(error_restore, post.ErrAuthorDeleted) {
+		return post.Post{}, errors.NewCustomError(
+			errors.Conflict,
+			"AUTHOR_DELETED",
+			"The post can't be restored while its author is deleted.",
+			fmt.Sprintf("Restore the author of the post with id '%d' first, which restores the posts deleted along with them.", id),
+			time.Now(),
+		)
+	}
+	if error_restore != nil {
+		return post.Post{}, errors.NewCustomError(
+			errors.KindOf(error_restore),
+			"ERROR_RESTORING_POST",
+			"An error occurred while restoring the post.",
+			error_restore.Error(),
+			time.Now(),
+		)
+	}
+	return service.getPost(ctx, id)
+}
+
+// ChangePostStatus moves the post through its lifecycle: draft, scheduled, published and archived. When
+// the version is not 0 the post is only changed while it is at that version.
+func (service *PostService) ChangePostStatus(ctx context.Context, id uint, version int, change post.StatusChange) (post.Post, *errors.CustomError) {
+	principal, err_actor := actingPrincipal(ctx)
+	if err_actor != nil {
+		return post.Post{}, err_actor
+	}
+
+	// Check if the post exists
+	existingPost, error_get := service.getPost(ctx, id)
+	if error_get != nil {
+		return post.Post{}, error_get
+	}
+
+	if err := checkVersion("post", id, version, existingPost.Version); err != nil {
+		return post.Post{}, err
+	}
+
+	if err := applyStatusChange(principal, &existingPost, change); err != nil {
+		return post.Post{}, err
+	}
+
+	// The status is only changed while the post is at the version read
+	error_update := service.Repository.UpdateStatus(ctx, id, existingPost.Version, existingPost.Status, existingPost.PublishedAt)
+	if error_update != nil {
+		return post.Post{}, errors.NewCustomError(
+			errors.KindOf(error_update),
+			"ERROR_UPDATING_POST",
+			"An error occurred while changing the status of the post.",
+			error_update.Error(),
+			time.Now(),
+		)
+	}
+	return service.getPost(ctx, id)
+}
+
+// PublishDuePosts publishes the scheduled posts whose publication time has come. It is run
+// periodically by the scheduler of the server.
+func (service *PostService) PublishDuePosts(ctx context.Context) (int64, *errors.CustomError) {
+	published, err := service.Repository.PublishDue(ctx, time.Now())
 	if err != nil {
-		return nil, errors.NewCustomError(
+		return 0, errors.NewCustomError(
+			errors.KindOf(err),
+			"ERROR_PUBLISHING_POSTS",
+			"An error occurred while publishing the scheduled posts.",
+			err.Error(),
+			time.Now(),
+		)
+	}
+	return published, nil
+}
+
+func (service *PostService) GetAllPosts(ctx context.Context, query post.Query) (page.Page[post.Post], *errors.CustomError) {
+	// Listings are public, so only published posts are listed unless another status is requested
+	// by someone allowed to see it
+	if query.Status == "" {
+		query.Status = post.StatusPublished
+	}
+	if !query.Status.IsValid() {
+		return page.Page[post.Post]{}, errors.NewCustomError(
+			errors.Validation,
+			"INVALID_QUERY",
+			fmt.Sprintf("The status '%s' does not exist.", query.Status),
+			"The status must be one of 'draft', 'scheduled', 'published' or 'archived'.",
+			time.Now(),
+		)
+	}
+	if query.Status != post.StatusPublished {
+		principal, _ := auth.PrincipalFromContext(ctx)
+		if !policy.CanListUnpublished(principal, query.AuthorID) {
+			return page.Page[post.Post]{}, forbiddenError(
+				"You are not allowed to list posts that are not published.",
+				"Authors can only list their own unpublished posts by filtering by their id in the 'author' parameter.",
+			)
+		}
+	}
+
+	if query.Page.Cursor != nil && len(query.Sort) > 0 {
+		return page.Page[post.Post]{}, errors.NewCustomError(
+			errors.Validation,
+			"INVALID_QUERY",
+			"Cursor pagination can't be combined with a custom sort.",
+			"Use the 'offset' parameter to paginate sorted listings.",
+			time.Now(),
+		)
+	}
+
+	query.Page = query.Page.Normalized()
+	posts, err := service.Repository.GetAll(ctx, query)
+	if err != nil {
+		return page.Page[post.Post]{}, errors.NewCustomError(
+			errors.KindOf(err),
 			"ERROR_GETTING_POSTS",
 			"An error occurred while getting all posts.",
 			err.Error(),
@@ -94,10 +325,99 @@ func (service *PostService) GetAllPosts(ctx context.Context) ([]post.Post, *erro
 	return posts, nil
 }
 
+func (service *PostService) Search(ctx context.Context, terms string, req page.Request) (page.Page[post.SearchResult], *errors.CustomError) {
+	if strings.TrimSpace(terms) == "" {
+		return page.Page[post.SearchResult]{}, errors.NewCustomError(
+			errors.Validation,
+			"EMPTY_FIELDS",
+			"The search terms can't be empty.",
+			"Send the terms to look for in the 'q' query parameter.",
+			time.Now(),
+		)
+	}
+
+	// Results are sorted by relevance, so they can't be paginated with a cursor
+	if req.Cursor != nil {
+		return page.Page[post.SearchResult]{}, errors.NewCustomError(
+			errors.Validation,
+			"INVALID_QUERY",
+			"Search results can't be paginated with a cursor.",
+			"Use the 'offset' parameter to paginate search results.",
+			time.Now(),
+		)
+	}
+
+	results, err := service.Repository.Search(ctx, terms, req.Normalized())
+	if err != nil {
+		return page.Page[post.SearchResult]{}, errors.NewCustomError(
+			errors.KindOf(err),
+			"ERROR_SEARCHING_POSTS",
+			"An error occurred while searching posts.",
+			err.Error(),
+			time.Now(),
+		)
+	}
+	return results, nil
+}
+
+// GetPostById returns the post if it is visible to the user making the request. Posts that are not
+// published are reported as not found to those who can't edit them.
 func (service *PostService) GetPostById(ctx context.Context, id uint) (post.Post, *errors.CustomError) {
+	existingPost, err := service.getPost(ctx, id)
+	if err != nil {
+		return post.Post{}, err
+	}
+
+	principal, _ := auth.PrincipalFromContext(ctx)
+	if !policy.CanViewPost(principal, existingPost) {
+		return post.Post{}, errors.NewCustomError(
+			errors.NotFound,
+			"ERROR_GETTING_POST",
+			"An error occurred while getting a post by its id.",
+			fmt.Sprintf("The post with id '%d' is not published.", id),
+			time.Now(),
+		)
+	}
+	return existingPost, nil
+}
+
+// GetPostBySlug returns the post with the slug, or the post that had it before its title changed, in
+// which case the returned post has a different slug. Visibility is checked as in GetPostById.
+func (service *PostService) GetPostBySlug(ctx context.Context, slug string) (post.Post, *errors.CustomError) {
+	existingPost, err := service.Repository.GetBySlug(ctx, slug)
+	if err != nil {
+		formerPost, err_former := service.Repository.GetByFormerSlug(ctx, slug)
+		if err_former != nil {
+			return post.Post{}, errors.NewCustomError(
+				errors.KindOf(err_former),
+				"ERROR_GETTING_POST",
+				fmt.Sprintf("There is not a post with slug '%s'.", slug),
+				err_former.Error(),
+				time.Now(),
+			)
+		}
+		existingPost = formerPost
+	}
+
+	principal, _ := auth.PrincipalFromContext(ctx)
+	if !policy.CanViewPost(principal, existingPost) {
+		return post.Post{}, errors.NewCustomError(
+			errors.NotFound,
+			"ERROR_GETTING_POST",
+			fmt.Sprintf("There is not a post with slug '%s'.", slug),
+			"The post is not published.",
+			time.Now(),
+		)
+	}
+	return existingPost, nil
+}
+
+// getPost returns the post whatever its status, for operations that check the permissions themselves.
+func (service *PostService) getPost(ctx context.Context, id uint) (post.Post, *errors.CustomError) {
 	existingPost, err := service.Repository.GetById(ctx, id)
 	if err != nil {
 		return post.Post{}, errors.NewCustomError(
+			errors.KindOf(err),
 			"ERROR_GETTING_POST",
 			"An error occurred while getting a post by its id.",
 			err.Error(),
@@ -107,6 +427,123 @@ func (service *PostService) GetPostById(ctx context.Context, id uint) (post.Post
 	return existingPost, nil
 }
 
-func (service *PostService) GetPostsByUserId(ctx context.Context, userId uint) ([]post.Post, *errors.CustomError) {
-	return nil, nil
+// GetPostsByUserId returns a page of the posts of the user, newest first. Only the published posts are
+// listed unless the user making the request can see the unpublished posts of the author.
+func (service *PostService) GetPostsByUserId(ctx context.Context, userId uint, req page.Request) (page.Page[post.Post], *errors.CustomError) {
+	status := post.StatusPublished
+	if principal, ok := auth.PrincipalFromContext(ctx); ok && policy.CanListUnpublished(principal, &userId) {
+		status = ""
+	}
+
+	posts, err := service.Repository.GetByUser(ctx, userId, status, req.Normalized())
+	if err != nil {
+		return page.Page[post.Post]{}, errors.NewCustomError(
+			errors.KindOf(err),
+			"ERROR_GETTING_POSTS",
+			"An error occurred while getting the posts of the user.",
+			err.Error(),
+			time.Now(),
+		)
+	}
+	return posts, nil
+}
+
+// renderPostBody converts the body of the post to sanitized HTML according to its format.
+func renderPostBody(p *post.Post) *errors.CustomError {
+	if !p.BodyFormat.IsValid() {
+		return errors.NewCustomError(
+			errors.Validation,
+			"INVALID_BODY_FORMAT",
+			fmt.Sprintf("The body format '%s' does not exist.", p.BodyFormat),
+			"The body format must be one of 'markdown', 'plain' or 'html'.",
+			time.Now(),
+		)
+	}
+
+	result, err := render.Render(render.Format(p.BodyFormat), p.Body)
+	if err != nil {
+		return errors.NewCustomError(
+			errors.Internal,
+			"ERROR_RENDERING_POST",
+			"An error occurred while rendering the body of the post.",
+			err.Error(),
+			time.Now(),
+		)
+	}
+	p.BodyHTML = result.HTML
+	p.TOC = postHeadings(result.TOC)
+	return nil
+}
+
+// postHeadings converts the table of contents built by the renderer to the one stored with the post.
+func postHeadings(headings []render.Heading) []post.Heading {
+	toc := make([]post.Heading, 0, len(headings))
+	for _, h := range headings {
+		heading := post.Heading{Level: h.Level, Text: h.Text, Anchor: h.Anchor}
+		if len(h.Children) > 0 {
+			heading.Children = postHeadings(h.Children)
+		}
+		toc = append(toc, heading)
+	}
+	return toc
+}
+
+// applyStatusChange validates the transition of the post to the new status, checks that the principal
+// is allowed to perform it, and updates the status and the publication time of the post.
+func applyStatusChange(principal auth.Principal, p *post.Post, change post.StatusChange) *errors.CustomError {
+	if !change.Status.IsValid() {
+		return errors.NewCustomError(
+			errors.Validation,
+			"INVALID_STATUS",
+			fmt.Sprintf("The status '%s' does not exist.", change.Status),
+			"The status must be one of 'draft', 'scheduled', 'published' or 'archived'.",
+			time.Now(),
+		)
+	}
+
+	// A scheduled post can be rescheduled
+	rescheduling := p.Status == post.StatusScheduled && change.Status == post.StatusScheduled
+	if !rescheduling && !p.Status.CanTransitionTo(change.Status) {
+		return errors.NewCustomError(
+			errors.Conflict,
+			"INVALID_STATUS_TRANSITION",
+			fmt.Sprintf("A %s post can't be moved to %s.", p.Status, change.Status),
+			"Drafts can be scheduled or published, scheduled posts published or moved back to draft, "+
+				"published posts archived or moved back to draft, and archived posts published again or moved back to draft.",
+			time.Now(),
+		)
+	}
+
+	// Publishing, scheduling and unpublishing require the permission to publish
+	allowed := policy.CanEditPost(principal, *p)
+	if change.Status == post.StatusPublished || change.Status == post.StatusScheduled || p.Status == post.StatusPublished {
+		allowed = policy.CanPublishPost(principal, *p)
+	}
+	if !allowed {
+		return forbiddenError(
+			"You are not allowed to change the status of this post.",
+			fmt.Sprintf("Your role does not allow to move this post to %s.", change.Status),
+		)
+	}
+
+	now := time.Now()
+	switch change.Status {
+	case post.StatusScheduled:
+		if change.PublishAt == nil || !change.PublishAt.After(now) {
+			return errors.NewCustomError(
+				errors.Validation,
+				"INVALID_PUBLISH_DATE",
+				"A scheduled post needs a publication date in the future.",
+				"Send the date in the 'publish_at' field.",
+				time.Now(),
+			)
+		}
+		p.PublishedAt = change.PublishAt
+	case post.StatusPublished:
+		p.PublishedAt = &now
+	case post.StatusDraft:
+		p.PublishedAt = nil
+	}
+	p.Status = change.Status
+	return nil
 }
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/tag"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+	"github.com/cortzero/go-postgres-blog/internal/service/slug"
+)
+
+// TagService is a service layer component that lists the tags used to organize posts
+type TagService struct {
+	Repository tag.Repository
+}
+
+func NewTagService(repository tag.Repository) *TagService {
+	return &TagService{
+		Repository: repository,
+	}
+}
+
+func (service *TagService) GetAllTags(ctx context.Context) ([]tag.Tag, *errors.CustomError) {
+	tags, err := service.Repository.GetAll(ctx)
+	if err != nil {
+		return nil, errors.NewCustomError(
+			errors.KindOf(err),
+			"ERROR_GETTING_TAGS",
+			"An error occurred while getting all tags.",
+			err.Error(),
+			time.Now(),
+		)
+	}
+	return tags, nil
+}
+
+func (service *TagService) GetTagBySlug(ctx context.Context, slug string) (tag.Tag, *errors.CustomError) {
+	t, err := service.Repository.GetBySlug(ctx, slug)
+	if err != nil {
+		return tag.Tag{}, errors.NewCustomError(
+			errors.KindOf(err),
+			"RESOURCE_NOT_FOUND",
+			fmt.Sprintf("There is not a tag with slug '%s'.", slug),
+			err.Error(),
+			time.Now(),
+		)
+	}
+	return t, nil
+}
+
+// normalizeTags trims the tag names and removes the ones that are repeated, two names being the
+// same tag when they have the same slug. A nil slice is kept nil, meaning that the tags are not changed.
+func normalizeTags(names []string) ([]string, *errors.CustomError) {
+	if names == nil {
+		return nil, nil
+	}
+
+	normalized := []string{}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		tagSlug := slug.Truncate(slug.Make(name), tag.MaxSlugLength)
+		if tagSlug == "" {
+			return nil, errors.NewCustomError(
+				errors.Validation,
+				"INVALID_TAG",
+				fmt.Sprintf("The tag '%s' is not valid.", name),
+				"A tag must contain at least one letter or digit.",
+				time.Now(),
+			)
+		}
+		if utf8.RuneCountInString(name) > tag.MaxNameLength {
+			return nil, errors.NewCustomError(
+				errors.Validation,
+				"INVALID_TAG",
+				fmt.Sprintf("The tag '%s' is too long.", name),
+				fmt.Sprintf("A tag can have at most %d characters.", tag.MaxNameLength),
+				time.Now(),
+			)
+		}
+		if seen[tagSlug] {
+			continue
+		}
+		seen[tagSlug] = true
+		normalized = append(normalized, name)
+	}
+
+	if len(normalized) > tag.MaxPerPost {
+		return nil, errors.NewCustomError(
+			errors.Validation,
+			"INVALID_TAG",
+			"The post has too many tags.",
+			fmt.Sprintf("A post can have at most %d tags.", tag.MaxPerPost),
+			time.Now(),
+		)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/page"
+	"github.com/cortzero/go-postgres-blog/internal/model/trash"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+	"github.com/cortzero/go-postgres-blog/internal/service/policy"
+)
+
+// TrashService is a service layer component that lists the deleted posts and users and purges the ones
+// that have been in the trash for longer than the retention period
+type TrashService struct {
+	Repository trash.Repository
+	Retention  time.Duration
+}
+
+func NewTrashService(repository trash.Repository, retention time.Duration) *TrashService {
+	return &TrashService{
+		Repository: repository,
+		Retention:  retention,
+	}
+}
+
+// GetTrash returns a page of the deleted posts and users along with the time they will be purged.
+func (service *TrashService) GetTrash(ctx context.Context, req page.Request) (page.Page[trash.Item], *errors.CustomError) {
+	principal, err_actor := actingPrincipal(ctx)
+	if err_actor != nil {
+		return page.Page[trash.Item]{}, err_actor
+	}
+	if !policy.CanManageTrash(principal) {
+		return page.Page[trash.Item]{}, forbiddenError(
+			"You are not allowed to see the trash.",
+			"Only admins can see the deleted posts and users.",
+		)
+	}
+
+	// Posts and users share the listing and their ids can be the same, so it can't be paginated with a cursor
+	if req.Cursor != nil {
+		return page.Page[trash.Item]{}, errors.NewCustomError(
+			errors.Malformed,
+			"BAD_REQUEST",
+			"The trash can't be paginated with a cursor.",
+			"Use the 'offset' parameter to paginate the trash.",
+			time.Now(),
+		)
+	}
+
+	items, err := service.Repository.GetAll(ctx, req.Normalized())
+	if err != nil {
+		return page.Page[trash.Item]{}, errors.NewCustomError(
+			errors.KindOf(err),
+			"ERROR_GETTING_TRASH",
+			"An error occurred while getting the trash.",
+			err.Error(),
+			time.Now(),
+		)
+	}
+	for i := range items.Items {
+		items.Items[i].PurgeAt = items.Items[i].DeletedAt.Add(service.Retention)
+	}
+	return items, nil
+}
+
+// PurgeTrash permanently removes the posts and users deleted longer than the retention period ago. It is
+// run by a background job, so it does not check the user making the request.
+func (service *TrashService) PurgeTrash(ctx context.Context) (trash.Purged, *errors.CustomError) {
+	purged, err := service.Repository.Purge(ctx, time.Now().Add(-service.Retention))
+	if err != nil {
+		return trash.Purged{}, errors.NewCustomError(
+			errors.KindOf(err),
+			"ERROR_PURGING_TRASH",
+			"An error occurred while purging the trash.",
+			err.Error(),
+			time.Now(),
+		)
+	}
+	return purged, nil
+}
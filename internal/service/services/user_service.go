@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/cortzero/go-postgres-blog/internal/model/page"
 	"github.com/cortzero/go-postgres-blog/internal/model/user"
 	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+	"github.com/cortzero/go-postgres-blog/internal/service/policy"
 )
 
 // UserService is a service layer component that manages the CRUD operations for users
@@ -20,13 +22,21 @@ func NewUserService(repository user.Repository) *UserService {
 	}
 }
 
-func (service *UserService) CreateUser(ctx context.Context, user *user.User) *errors.CustomError {
+func (service *UserService) CreateUser(ctx context.Context, newUser *user.User) *errors.CustomError {
 	// Sets the timestamp at which the user is created
-	user.CreatedAt = time.Now()
+	newUser.CreatedAt = time.Now()
+
+	// New accounts are always authors, roles can only be granted by an admin
+	newUser.Role = user.RoleAuthor
+
+	if err := validateNewUser(newUser); err != nil {
+		return err
+	}
 
 	// Hashes the password
-	if err := user.HashPassword(); err != nil {
+	if err := newUser.HashPassword(); err != nil {
 		return errors.NewCustomError(
+			errors.Internal,
 			"ERROR_HASHING_PASSWORD",
 			"An error occurred while hashing the user password.",
 			err.Error(),
@@ -34,10 +44,15 @@ func (service *UserService) CreateUser(ctx context.Context, user *user.User) *er
 		)
 	}
 
-	// Creating the user
-	err := service.Repository.Create(ctx, user)
+	// Creating the user. The unique indexes of the database tell whether the username or the email are
+	// taken, so that two concurrent signups can't get the same ones
+	err := service.Repository.Create(ctx, newUser)
 	if err != nil {
+		if err_taken := userTakenError(err); err_taken != nil {
+			return err_taken
+		}
 		return errors.NewCustomError(
+			errors.KindOf(err),
 			"ERROR_CREATING_USER",
 			"An error occurred while creating the user.",
 			err.Error(),
@@ -47,76 +62,230 @@ func (service *UserService) CreateUser(ctx context.Context, user *user.User) *er
 	return nil
 }
 
-func (service *UserService) UpdateUser(ctx context.Context, id uint, user *user.User) *errors.CustomError {
+// UpdateUser changes the profile of the user, except for the picture, which is kept as it is. When
+// updatedUser.Version is set the user is only changed while it is at that version.
+func (service *UserService) UpdateUser(ctx context.Context, id uint, updatedUser *user.User) (user.User, *errors.CustomError) {
+	return service.updateUser(ctx, id, updatedUser, false)
+}
+
+// PatchUser is UpdateUser for a user built from a patched profile, which also sets the picture, so that
+// a patch can clear it.
+func (service *UserService) PatchUser(ctx context.Context, id uint, patchedUser *user.User) (user.User, *errors.CustomError) {
+	return service.updateUser(ctx, id, patchedUser, true)
+}
+
+func (service *UserService) updateUser(ctx context.Context, id uint, updatedUser *user.User, withPicture bool) (user.User, *errors.CustomError) {
+	// Users can only update their own account unless they are admins
+	if err := checkCanManageUser(ctx, id, "You are not allowed to update this user."); err != nil {
+		return user.User{}, err
+	}
+
 	// Check if user exists
 	existingUser, err := service.GetUserById(ctx, id)
 	if err != nil {
-		return err
+		return user.User{}, err
 	}
 
-	// Check that all fields are not empty
-	if user.FirstName == "" || user.LastName == "" || user.Email == "" {
-		return errors.NewCustomError(
-			"EMPTY_FIELDS",
-			"You cannot leave user fields empty.",
-			"Fill the corresponding fields to update the user.",
-			time.Now(),
-		)
+	if existingUser.Username == user.GhostUsername {
+		return user.User{}, ghostUserError("updated")
 	}
 
-	// Check if there is another user with the new email
-	_, err = service.GetUserByEmail(ctx, user.Email)
-	if err == nil {
-		return errors.NewCustomError(
-			"REPEATED_EMAIL",
-			"There can't be two users with the same email.",
-			"You need to choose another email for this user.",
-			time.Now(),
-		)
+	if err := checkVersion("user", id, updatedUser.Version, existingUser.Version); err != nil {
+		return user.User{}, err
+	}
+
+	if err := validateUserProfile(updatedUser); err != nil {
+		return user.User{}, err
 	}
 
 	// Updating the existing user
-	existingUser.FirstName = user.FirstName
-	existingUser.LastName = user.LastName
-	existingUser.Email = user.Email
+	existingUser.FirstName = updatedUser.FirstName
+	existingUser.LastName = updatedUser.LastName
+	existingUser.Email = updatedUser.Email
+	if withPicture {
+		existingUser.Picture = updatedUser.Picture
+	}
 	existingUser.UpdatedAt = time.Now()
+
+	// Another user having the new email is detected by the unique index
 	err_update := service.Repository.Update(ctx, id, existingUser)
 	if err_update != nil {
-		return errors.NewCustomError(
+		if err_taken := userTakenError(err_update); err_taken != nil {
+			return user.User{}, err_taken
+		}
+		return user.User{}, errors.NewCustomError(
+			errors.KindOf(err_update),
 			"ERROR_UPDATING_USER",
 			"An error occurred while updating the user.",
 			err_update.Error(),
 			time.Now(),
 		)
 	}
-	return nil
+	return existingUser, nil
 }
 
-func (service *UserService) DeleteUser(ctx context.Context, id uint) *errors.CustomError {
+// UpdateUserRole changes the role of the user. When the version is not 0 the user is only changed while it
+// is at that version.
+func (service *UserService) UpdateUserRole(ctx context.Context, id uint, version int, role user.Role) (user.User, *errors.CustomError) {
+	principal, err_actor := actingPrincipal(ctx)
+	if err_actor != nil {
+		return user.User{}, err_actor
+	}
+	if !policy.CanChangeRoles(principal) {
+		return user.User{}, forbiddenError(
+			"You are not allowed to change the role of a user.",
+			"Only admins can change roles.",
+		)
+	}
+
+	if !role.IsValid() {
+		return user.User{}, errors.NewCustomError(
+			errors.Validation,
+			"INVALID_ROLE",
+			fmt.Sprintf("The role '%s' does not exist.", role),
+			"The role must be one of 'admin', 'editor' or 'author'.",
+			time.Now(),
+		)
+	}
+
+	// Check if user exists
+	existingUser, err := service.GetUserById(ctx, id)
+	if err != nil {
+		return user.User{}, err
+	}
+	if existingUser.Username == user.GhostUsername {
+		return user.User{}, ghostUserError("given another role")
+	}
+
+	if err := checkVersion("user", id, version, existingUser.Version); err != nil {
+		return user.User{}, err
+	}
+
+	// The role is only changed while the user is at the version read
+	err_update := service.Repository.UpdateRole(ctx, id, existingUser.Version, role)
+	if err_update != nil {
+		return user.User{}, errors.NewCustomError(
+			errors.KindOf(err_update),
+			"ERROR_UPDATING_USER",
+			"An error occurred while updating the role of the user.",
+			err_update.Error(),
+			time.Now(),
+		)
+	}
+	return service.GetUserById(ctx, id)
+}
+
+// DeleteUser moves the user to the trash and, depending on the policy of the deletion, moves their posts
+// to the trash too, gives them to another user or gives them and the comments of the user to the ghost
+// account. The sessions of the user are revoked. Everything happens in a single transaction. When the version is not 0 the user is only deleted
+// while it is at that version. A dry run reports what would be affected without changing anything.
+func (service *UserService) DeleteUser(ctx context.Context, id uint, version int, deletion user.Deletion) (user.DeletionReport, *errors.CustomError) {
+	// Users can only delete their own account unless they are admins
+	if err := checkCanManageUser(ctx, id, "You are not allowed to delete this user."); err != nil {
+		return user.DeletionReport{}, err
+	}
+
 	// Check if the user exists with the given id
-	_, err := service.GetUserById(ctx, id)
+	existingUser, err := service.GetUserById(ctx, id)
 	if err != nil {
-		return err
+		return user.DeletionReport{}, err
+	}
+
+	// The ghost account holds the anonymized posts and comments, so it can't go away
+	if existingUser.Username == user.GhostUsername {
+		return user.DeletionReport{}, ghostUserError("deleted")
+	}
+
+	if err := checkVersion("user", id, version, existingUser.Version); err != nil {
+		return user.DeletionReport{}, err
+	}
+
+	// The policy is required, so that the posts of the user are never trashed by omission
+	if err := validateDeletion(id, deletion); err != nil {
+		return user.DeletionReport{}, err
+	}
+
+	if deletion.Policy == user.DeletionReassign {
+		principal, err_actor := actingPrincipal(ctx)
+		if err_actor != nil {
+			return user.DeletionReport{}, err_actor
+		}
+		if !policy.CanReassignPosts(principal) {
+			return user.DeletionReport{}, forbiddenError(
+				"You are not allowed to give the posts of this user to another user.",
+				"Only admins can reassign posts, use the 'cascade' or 'anonymize' policies instead.",
+			)
+		}
+
+		// Check that the user receiving the posts exists
+		if _, err := service.Repository.GetById(ctx, deletion.ReassignTo); err != nil {
+			if errors.KindOf(err) != errors.NotFound {
+				return user.DeletionReport{}, errors.NewCustomError(
+					errors.KindOf(err),
+					"ERROR_DELETING",
+					"An error occurred while removing the user.",
+					err.Error(),
+					time.Now(),
+				)
+			}
+			err_receiver := errors.NewCustomError(
+				errors.Validation,
+				"VALIDATION_FAILED",
+				"The deletion is not valid.",
+				"Check the fields 'reassign_to'.",
+				time.Now(),
+			)
+			err_receiver.Fields = []errors.FieldError{{Field: "reassign_to", Message: "There is not a user with this id."}}
+			return user.DeletionReport{}, err_receiver
+		}
 	}
 
 	// Deleting the user
-	err_delete := service.Repository.Delete(ctx, id)
+	report, err_delete := service.Repository.Delete(ctx, id, existingUser.Version, deletion)
 	if err_delete != nil {
-		return errors.NewCustomError(
+		return user.DeletionReport{}, errors.NewCustomError(
+			errors.KindOf(err_delete),
 			"ERROR_DELETING",
 			"An error occurred while removing the user.",
 			err_delete.Error(),
 			time.Now(),
 		)
 	}
-	return nil
+	return report, nil
+}
+
+// RestoreUser takes the user out of the trash along with the posts that were deleted with them.
+func (service *UserService) RestoreUser(ctx context.Context, id uint) (user.User, *errors.CustomError) {
+	principal, err_actor := actingPrincipal(ctx)
+	if err_actor != nil {
+		return user.User{}, err_actor
+	}
+	if !policy.CanManageTrash(principal) {
+		return user.User{}, forbiddenError(
+			"You are not allowed to restore users.",
+			"Only admins can restore deleted users.",
+		)
+	}
+
+	err_restore := service.Repository.Restore(ctx, id)
+	if err_restore != nil {
+		return user.User{}, errors.NewCustomError(
+			errors.KindOf(err_restore),
+			"ERROR_RESTORING_USER",
+			"An error occurred while restoring the user.",
+			err_restore.Error(),
+			time.Now(),
+		)
+	}
+	return service.GetUserById(ctx, id)
 }
 
-func (service *UserService) GetAllUsers(ctx context.Context) ([]user.User, *errors.CustomError) {
-	users, err := service.Repository.GetAll(ctx)
+func (service *UserService) GetAllUsers(ctx context.Context, req page.Request) (page.Page[user.User], *errors.CustomError) {
+	users, err := service.Repository.GetAll(ctx, req.Normalized())
 	if err != nil {
-		return nil, errors.NewCustomError(
-			"RESOURCE_NOT_FOUND",
+		return page.Page[user.User]{}, errors.NewCustomError(
+			errors.KindOf(err),
+			"ERROR_GETTING_USERS",
 			"An error occurred while looking for all users.",
 			err.Error(),
 			time.Now(),
@@ -129,6 +298,7 @@ func (service *UserService) GetUserById(ctx context.Context, id uint) (user.User
 	u, err := service.Repository.GetById(ctx, id)
 	if err != nil {
 		return user.User{}, errors.NewCustomError(
+			errors.KindOf(err),
 			"RESOURCE_NOT_FOUND",
 			fmt.Sprintf("There is not a user with id '%d'.", id),
 			err.Error(),
@@ -142,6 +312,7 @@ func (service *UserService) GetUserByUsername(ctx context.Context, username stri
 	u, err := service.Repository.GetByUsername(ctx, username)
 	if err != nil {
 		return user.User{}, errors.NewCustomError(
+			errors.KindOf(err),
 			"RESOURCE_NOT_FOUND",
 			fmt.Sprintf("There is not a user with username '%s'.", username),
 			err.Error(),
@@ -155,6 +326,7 @@ func (service *UserService) GetUserByEmail(ctx context.Context, email string) (u
 	u, err := service.Repository.GetByEmail(ctx, email)
 	if err != nil {
 		return user.User{}, errors.NewCustomError(
+			errors.KindOf(err),
 			"RESOURCE_NOT_FOUND",
 			fmt.Sprintf("There is not a user with email '%s'.", email),
 			err.Error(),
@@ -163,3 +335,57 @@ func (service *UserService) GetUserByEmail(ctx context.Context, email string) (u
 	}
 	return u, nil
 }
+
+// checkCanManageUser verifies that the authenticated user can manage the account with the given id.
+func checkCanManageUser(ctx context.Context, id uint, message string) *errors.CustomError {
+	principal, err := actingPrincipal(ctx)
+	if err != nil {
+		return err
+	}
+	if !policy.CanManageUser(principal, id) {
+		return forbiddenError(
+			message,
+			"Users can only manage their own account.",
+		)
+	}
+	return nil
+}
+
+// ghostUserError is returned when a change is made to the ghost account, which the anonymize deletion
+// policy looks up by its username.
+func ghostUserError(change string) *errors.CustomError {
+	return errors.NewCustomError(
+		errors.Conflict,
+		"GHOST_USER",
+		fmt.Sprintf("The ghost account can't be %s.", change),
+		"The ghost account owns the posts and comments of the anonymized users.",
+		time.Now(),
+	)
+}
+
+// userTakenError returns the error for a username or an email that another user already has, or nil when
+// the repository failed for another reason.
+func userTakenError(err error) *errors.CustomError {
+	var newError *errors.CustomError
+	switch {
+	case errors.Is(err, user.ErrUsernameTaken):
+		newError = errors.NewCustomError(
+			errors.Conflict,
+			"USERNAME_TAKEN",
+			"The username is already taken.",
+			"Usernames are unique regardless of their case. Choose another one.",
+			time.Now(),
+		)
+		newError.Fields = []errors.FieldError{{Field: "username", Message: "This username is already taken."}}
+	case errors.Is(err, user.ErrEmailTaken):
+		newError = errors.NewCustomError(
+			errors.Conflict,
+			"EMAIL_TAKEN",
+			"The email is already used by another account.",
+			"Emails are unique regardless of their case. Use another one.",
+			time.Now(),
+		)
+		newError.Fields = []errors.FieldError{{Field: "email", Message: "This email is already used by another account."}}
+	}
+	return newError
+}
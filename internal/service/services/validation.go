@@ -0,0 +1,70 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/cortzero/go-postgres-blog/internal/model/post"
+	"github.com/cortzero/go-postgres-blog/internal/model/user"
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+	"github.com/cortzero/go-postgres-blog/internal/service/validation"
+)
+
+// usernameRegExp keeps usernames safe to use in URLs
+var usernameRegExp = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// validateNewUser checks the fields of the account being created.
+func validateNewUser(u *user.User) *errors.CustomError {
+	v := validation.New()
+	v.Field("username", u.Username).Required().
+		MinLength(user.MinUsernameLength).
+		MaxLength(user.MaxUsernameLength).
+		Matches(usernameRegExp, "This field can only contain letters, digits, '_', '.' and '-'.")
+	v.Field("password", u.Password).Required().
+		MinLength(user.MinPasswordLength).
+		MaxBytes(user.MaxPasswordBytes)
+	checkUserProfile(v, u)
+	return v.Error("The user is not valid.")
+}
+
+// validateUserProfile checks the fields of the account that its owner can update.
+func validateUserProfile(u *user.User) *errors.CustomError {
+	v := validation.New()
+	checkUserProfile(v, u)
+	return v.Error("The user is not valid.")
+}
+
+func checkUserProfile(v *validation.Validator, u *user.User) {
+	v.Field("first_name", u.FirstName).Required().MaxLength(user.MaxNameLength)
+	v.Field("last_name", u.LastName).Required().MaxLength(user.MaxNameLength)
+	v.Field("email", u.Email).Required().MaxLength(user.MaxEmailLength).Email()
+	v.Field("picture", u.Picture).Optional().MaxLength(user.MaxPictureLength).HTTPURL()
+}
+
+// validateDeletion checks the policy of the deletion of the user with the given id and the user that
+// receives their posts.
+func validateDeletion(id uint, deletion user.Deletion) *errors.CustomError {
+	v := validation.New()
+	v.Field("policy", string(deletion.Policy)).Required().
+		OneOf(string(user.DeletionCascade), string(user.DeletionReassign), string(user.DeletionAnonymize))
+	if deletion.Policy == user.DeletionReassign {
+		reassignTo := ""
+		if deletion.ReassignTo != 0 {
+			reassignTo = strconv.FormatUint(uint64(deletion.ReassignTo), 10)
+		}
+		v.Field("reassign_to", reassignTo).Required().
+			Check(deletion.ReassignTo != id, "The posts can't be given to the user being deleted.")
+	}
+	return v.Error("The deletion is not valid.")
+}
+
+// validatePost checks the fields of a post being created or updated. An empty body format keeps the
+// current one, or plain text for new posts.
+func validatePost(p *post.Post) *errors.CustomError {
+	v := validation.New()
+	v.Field("title", p.Title).Required().MaxLength(post.MaxTitleLength)
+	v.Field("body", p.Body).Required()
+	v.Field("body_format", string(p.BodyFormat)).Optional().
+		OneOf(string(post.FormatMarkdown), string(post.FormatPlain), string(post.FormatHTML))
+	return v.Error("The post is not valid.")
+}
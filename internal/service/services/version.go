@@ -0,0 +1,23 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+)
+
+// checkVersion returns an error when the change expects a version of the resource other than its
+// current one. An expected version of 0 means that the change does not expect any.
+func checkVersion(resource string, id uint, expected int, current int) *errors.CustomError {
+	if expected == 0 || expected == current {
+		return nil
+	}
+	return errors.NewCustomError(
+		errors.PreconditionFailed,
+		"VERSION_MISMATCH",
+		fmt.Sprintf("The %s was changed by another request.", resource),
+		fmt.Sprintf("The %s with id '%d' is at version %d, not %d. Get it again and retry the change.", resource, id, current, expected),
+		time.Now(),
+	)
+}
@@ -0,0 +1,151 @@
+// Package validation checks the fields of the requests with chained rules, collecting the errors of
+// every field so that they are reported at once.
+//
+//	v := validation.New()
+//	v.Field("username", u.Username).Required().MaxLength(30)
+//	v.Field("email", u.Email).Required().Email()
+//	if err := v.Error("The user is not valid."); err != nil {
+//		return err
+//	}
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cortzero/go-postgres-blog/internal/service/errors"
+)
+
+// Validator collects the errors of the fields of a request
+type Validator struct {
+	fields []errors.FieldError
+}
+
+func New() *Validator {
+	return &Validator{}
+}
+
+// Field starts the rules of a field. The rules of a field stop at the first one that fails, so there is
+// at most an error per field.
+func (v *Validator) Field(name string, value string) *Field {
+	return &Field{
+		validator: v,
+		name:      name,
+		value:     value,
+	}
+}
+
+// Valid reports whether every rule passed.
+func (v *Validator) Valid() bool {
+	return len(v.fields) == 0
+}
+
+// Errors returns the errors of the fields in the order the rules were checked.
+func (v *Validator) Errors() []errors.FieldError {
+	return v.fields
+}
+
+// Error returns a VALIDATION_FAILED error listing the invalid fields, or nil if every rule passed.
+func (v *Validator) Error(message string) *errors.CustomError {
+	if v.Valid() {
+		return nil
+	}
+	names := make([]string, 0, len(v.fields))
+	for _, field := range v.fields {
+		names = append(names, "'"+field.Field+"'")
+	}
+	err := errors.NewCustomError(
+		errors.Validation,
+		"VALIDATION_FAILED",
+		message,
+		fmt.Sprintf("Check the fields %s.", strings.Join(names, ", ")),
+		time.Now(),
+	)
+	err.Fields = v.fields
+	return err
+}
+
+// Field is a value of the request being checked
+type Field struct {
+	validator *Validator
+	name      string
+	value     string
+	done      bool
+}
+
+// Check fails the field with the message when ok is false.
+func (f *Field) Check(ok bool, message string) *Field {
+	if f.done || ok {
+		return f
+	}
+	f.validator.fields = append(f.validator.fields, errors.FieldError{Field: f.name, Message: message})
+	f.done = true
+	return f
+}
+
+// Required fails when the field is empty or blank.
+func (f *Field) Required() *Field {
+	return f.Check(strings.TrimSpace(f.value) != "", "This field is required.")
+}
+
+// Optional skips the following rules when the field is empty.
+func (f *Field) Optional() *Field {
+	if f.value == "" {
+		f.done = true
+	}
+	return f
+}
+
+// MinLength fails when the field has fewer characters than min.
+func (f *Field) MinLength(min int) *Field {
+	return f.Check(utf8.RuneCountInString(f.value) >= min, fmt.Sprintf("This field must have at least %d characters.", min))
+}
+
+// MaxLength fails when the field has more characters than max.
+func (f *Field) MaxLength(max int) *Field {
+	return f.Check(utf8.RuneCountInString(f.value) <= max, fmt.Sprintf("This field can have at most %d characters.", max))
+}
+
+// MaxBytes fails when the field takes more than max bytes once encoded in UTF-8.
+func (f *Field) MaxBytes(max int) *Field {
+	return f.Check(len(f.value) <= max, fmt.Sprintf("This field can take at most %d bytes.", max))
+}
+
+// Matches fails with the message when the field does not match the regular expression.
+func (f *Field) Matches(re *regexp.Regexp, message string) *Field {
+	return f.Check(re.MatchString(f.value), message)
+}
+
+// OneOf fails when the field is not one of the values.
+func (f *Field) OneOf(values ...string) *Field {
+	for _, value := range values {
+		if f.value == value {
+			return f
+		}
+	}
+	return f.Check(false, fmt.Sprintf("This field must be one of '%s'.", strings.Join(values, "', '")))
+}
+
+// Email fails when the field is not a bare email address, without a display name.
+func (f *Field) Email() *Field {
+	address, err := mail.ParseAddress(f.value)
+	ok := err == nil && address.Name == "" && address.Address == f.value
+	if ok {
+		// The domain must have at least a dot, addresses at a bare host name are not accepted
+		domain := address.Address[strings.LastIndex(address.Address, "@")+1:]
+		ok = strings.Contains(domain, ".")
+	}
+	return f.Check(ok, "This field must be a valid email address.")
+}
+
+// HTTPURL fails when the field is not an absolute http or https URL.
+func (f *Field) HTTPURL() *Field {
+	u, err := url.Parse(f.value)
+	ok := err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+	return f.Check(ok, "This field must be an http or https URL.")
+}